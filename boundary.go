@@ -0,0 +1,167 @@
+package geokit
+
+import (
+	"math"
+
+	"github.com/golang/geo/r3"
+	"github.com/golang/geo/s2"
+)
+
+// CellsToBoundaryPolygon dissolves cellIDs into the outline of their union,
+// returned as a Polygon geometry, or a MultiPolygon if the cells form
+// several disjoint regions. Holes in the union become interior rings. If
+// there are no cells, nil is returned.
+func CellsToBoundaryPolygon(cellIDs []s2.CellID) *GeoJSONGeometry {
+	if len(cellIDs) == 0 {
+		return nil
+	}
+
+	cu := s2.CellUnion(append([]s2.CellID(nil), cellIDs...))
+	cu.Normalize()
+
+	var segments []boundarySegment
+	for _, cellID := range cu {
+		for k := 0; k < 4; k++ {
+			segments = appendBoundarySegments(segments, cu, cellID, k)
+		}
+	}
+
+	var shells, holes []*s2.Loop
+	for _, ring := range chainSegments(segments) {
+		// boundary segments keep the union on their left, so shells come
+		// out counter-clockwise and holes come out clockwise
+		loop := s2.LoopFromPoints(ring)
+		if loop.IsNormalized() {
+			shells = append(shells, loop)
+		} else {
+			holes = append(holes, loop)
+		}
+	}
+
+	polygons := make([][][][2]float64, len(shells))
+	for i, shell := range shells {
+		polygons[i] = [][][2]float64{loopToRing(shell)}
+	}
+	for _, hole := range holes {
+		// Invert reverses vertices in place, so work on a copy
+		inverted := s2.LoopFromPoints(append([]s2.Point(nil), hole.Vertices()...))
+		inverted.Invert()
+		for i, shell := range shells {
+			if shell.Contains(inverted) {
+				polygons[i] = append(polygons[i], loopToRing(hole))
+				break
+			}
+		}
+	}
+
+	if len(polygons) == 1 {
+		return &GeoJSONGeometry{Type: "Polygon", Coordinates: polygons[0]}
+	}
+	return &GeoJSONGeometry{Type: "MultiPolygon", Coordinates: polygons}
+}
+
+// boundarySegment is a directed piece of edge k of a cell on face
+type boundarySegment struct {
+	start, end s2.Point
+	face, k    int
+}
+
+// appendBoundarySegments appends the parts of edge k of cellID that lie on
+// the boundary of cu. Where cu only partly covers the far side of the edge,
+// the edge is split between the children of cellID that touch it.
+func appendBoundarySegments(segments []boundarySegment, cu s2.CellUnion, cellID s2.CellID, k int) []boundarySegment {
+	neighbor := cellID.EdgeNeighbors()[k]
+	if cu.ContainsCellID(neighbor) {
+		return segments
+	}
+
+	if !cu.IntersectsCellID(neighbor) || cellID.IsLeaf() {
+		cell := s2.CellFromCellID(cellID)
+		return append(segments, boundarySegment{
+			start: cell.Vertex(k),
+			end:   cell.Vertex((k + 1) % 4),
+			face:  cellID.Face(),
+			k:     k,
+		})
+	}
+
+	for _, child := range cellID.Children() {
+		// children whose neighbor is a sibling don't touch edge k
+		if child.EdgeNeighbors()[k].Parent(cellID.Level()) == cellID {
+			continue
+		}
+		segments = appendBoundarySegments(segments, cu, child, k)
+	}
+
+	return segments
+}
+
+// chainSegments joins directed segments end to start into closed rings,
+// dropping vertices that sit in the middle of a straight edge.
+func chainSegments(segments []boundarySegment) [][]s2.Point {
+	byStart := make(map[r3.Vector][]int)
+	for i, seg := range segments {
+		key := pointKey(seg.start)
+		byStart[key] = append(byStart[key], i)
+	}
+
+	used := make([]bool, len(segments))
+	var rings [][]s2.Point
+	for i := range segments {
+		if used[i] {
+			continue
+		}
+
+		var chain []boundarySegment
+		for next := i; next >= 0 && !used[next]; {
+			used[next] = true
+			chain = append(chain, segments[next])
+
+			candidates := byStart[pointKey(segments[next].end)]
+			next = -1
+			for _, c := range candidates {
+				if !used[c] {
+					next = c
+					break
+				}
+			}
+		}
+
+		// consecutive segments along the same edge direction of the same
+		// face lie on one great circle, so the vertex between them adds
+		// nothing
+		var ring []s2.Point
+		for j, seg := range chain {
+			prev := chain[(j+len(chain)-1)%len(chain)]
+			if prev.face == seg.face && prev.k == seg.k && len(chain) > 1 {
+				continue
+			}
+			ring = append(ring, seg.start)
+		}
+
+		rings = append(rings, ring)
+	}
+
+	return rings
+}
+
+// vertices shared by cells on different faces aren't always bit-for-bit
+// identical, so round them before comparing
+func pointKey(p s2.Point) r3.Vector {
+	const scale = 1e12
+	return r3.Vector{
+		X: math.Round(p.X * scale),
+		Y: math.Round(p.Y * scale),
+		Z: math.Round(p.Z * scale),
+	}
+}
+
+// loopToRing returns the closed ring of [lng, lat] positions of l
+func loopToRing(l *s2.Loop) [][2]float64 {
+	var ring [][2]float64
+	for _, v := range l.Vertices() {
+		ll := s2.LatLngFromPoint(v)
+		ring = append(ring, [2]float64{ll.Lng.Degrees(), ll.Lat.Degrees()})
+	}
+	return append(ring, ring[0])
+}
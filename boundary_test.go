@@ -0,0 +1,120 @@
+package geokit
+
+import (
+	"testing"
+
+	"github.com/golang/geo/s2"
+)
+
+func TestCellsToBoundaryPolygon(t *testing.T) {
+	center := s2.CellIDFromLatLng(s2.LatLngFromDegrees(10, 10)).Parent(8)
+	far := s2.CellIDFromLatLng(s2.LatLngFromDegrees(-30, 100)).Parent(8)
+	children := center.Children()
+
+	for _, tt := range []struct {
+		name    string
+		cellIDs []s2.CellID
+		typ     string
+
+		// positions in each ring of each polygon, if checked
+		rings [][]int
+		hole  *s2.CellID
+	}{
+		{
+			name:    "one cell",
+			cellIDs: []s2.CellID{center},
+			typ:     "Polygon",
+			rings:   [][]int{{5}},
+		},
+		{
+			// the vertices in the middle of each side go
+			name:    "four children",
+			cellIDs: children[:],
+			typ:     "Polygon",
+			rings:   [][]int{{5}},
+		},
+		{
+			name:    "mixed levels",
+			cellIDs: []s2.CellID{children[0], children[1], children[2], children[3].Children()[0]},
+			typ:     "Polygon",
+		},
+		{
+			name:    "ring of neighbors",
+			cellIDs: center.AllNeighbors(8),
+			typ:     "Polygon",
+			rings:   [][]int{{5, 5}},
+			hole:    &center,
+		},
+		{
+			name:    "two regions",
+			cellIDs: []s2.CellID{center, far},
+			typ:     "MultiPolygon",
+			rings:   [][]int{{5}, {5}},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			geo := CellsToBoundaryPolygon(tt.cellIDs)
+			if geo == nil {
+				t.Fatal("got no geometry")
+			}
+			if geo.Type != tt.typ {
+				t.Fatalf("got a %s, want a %s", geo.Type, tt.typ)
+			}
+
+			var polys [][][][2]float64
+			if coords, ok := geo.Coordinates.([][][][2]float64); ok {
+				polys = coords
+			} else {
+				polys = [][][][2]float64{geo.Coordinates.([][][2]float64)}
+			}
+
+			if tt.rings != nil {
+				if len(polys) != len(tt.rings) {
+					t.Fatalf("got %d polygons, want %d", len(polys), len(tt.rings))
+				}
+				for i, poly := range polys {
+					if len(poly) != len(tt.rings[i]) {
+						t.Fatalf("polygon %d: got %d rings, want %d", i, len(poly), len(tt.rings[i]))
+					}
+					for j, ring := range poly {
+						if len(ring) != tt.rings[i][j] {
+							t.Errorf("polygon %d ring %d: got %d positions, want %d", i, j, len(ring), tt.rings[i][j])
+						}
+					}
+				}
+			}
+
+			var regions []*s2.Polygon
+			for i, poly := range polys {
+				p, err := GeoJSONPolygonToS2Polygon(&GeoJSONPolygonGeometry{Coordinates: poly})
+				if err != nil {
+					t.Fatalf("polygon %d: %v", i, err)
+				}
+				regions = append(regions, p)
+			}
+			contains := func(p s2.Point) bool {
+				for _, poly := range regions {
+					if poly.ContainsPoint(p) {
+						return true
+					}
+				}
+				return false
+			}
+
+			for _, cellID := range tt.cellIDs {
+				if !contains(cellID.Point()) {
+					t.Errorf("outline does not contain cell %s", cellID.ToToken())
+				}
+			}
+			if tt.hole != nil && contains(tt.hole.Point()) {
+				t.Errorf("outline contains the hole %s", tt.hole.ToToken())
+			}
+		})
+	}
+}
+
+func TestCellsToBoundaryPolygonEmpty(t *testing.T) {
+	if geo := CellsToBoundaryPolygon(nil); geo != nil {
+		t.Errorf("got %v, want nil", geo)
+	}
+}
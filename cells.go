@@ -0,0 +1,211 @@
+package geokit
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/golang/geo/s2"
+)
+
+// CellsToGeoJSONFeatureCollection returns a FeatureCollection with one
+// Polygon feature per cell. See CellToGeoJSONFeature. Coordinates are at
+// full precision; see RoundCoordinates and RoundBBox to shrink them.
+func CellsToGeoJSONFeatureCollection(cellIDs []s2.CellID) *GeoJSONFeatureCollection {
+	fc := GeoJSONFeatureCollection{
+		Type:     "FeatureCollection",
+		Features: make([]GeoJSONFeature, len(cellIDs)),
+	}
+
+	for i, cellID := range cellIDs {
+		fc.Features[i] = CellToGeoJSONFeature(cellID)
+	}
+	fc.BBox = CellsBBox(cellIDs)
+
+	return &fc
+}
+
+// WriteFeatureCollection streams the FeatureCollection that
+// CellsToGeoJSONFeatureCollection would return for cellIDs to w, one
+// feature at a time.
+func WriteFeatureCollection(w io.Writer, cellIDs []s2.CellID) error {
+	return StreamFeatureCollection(w, CellsBBox(cellIDs), len(cellIDs), func(i int) GeoJSONFeature {
+		return CellToGeoJSONFeature(cellIDs[i])
+	})
+}
+
+// CellsBBox returns the RFC 7946 bounding box of cellIDs, as [west, south,
+// east, north] degrees, or nil if there are no cells. West is greater than
+// east when the cells straddle the antimeridian.
+func CellsBBox(cellIDs []s2.CellID) []float64 {
+	if len(cellIDs) == 0 {
+		return nil
+	}
+
+	rect := s2.EmptyRect()
+	for _, cellID := range cellIDs {
+		rect = rect.Union(s2.CellFromCellID(cellID).RectBound())
+	}
+
+	lo, hi := rect.Lo(), rect.Hi()
+	return []float64{lo.Lng.Degrees(), lo.Lat.Degrees(), hi.Lng.Degrees(), hi.Lat.Degrees()}
+}
+
+// CellToGeoJSONFeature returns a Polygon feature outlining cellID, with the
+// cell's token as its id. The feature's center property holds the [lng, lat]
+// of the cell's center, its areaKm2 property the cell's approximate area at
+// EarthRadiusKm, and its labels property the cell's token, numeric id and
+// level.
+func CellToGeoJSONFeature(cellID s2.CellID) GeoJSONFeature {
+	var feat GeoJSONFeature
+
+	cellToken := cellID.ToToken()
+	cell := s2.CellFromCellID(s2.CellIDFromToken(cellToken))
+
+	feat.Type = "Feature"
+	feat.ID = cellToken
+
+	center := s2.LatLngFromPoint(cell.Center())
+
+	feat.Properties = map[string]interface{}{
+		"entity_id": cellToken,
+		"center":    [2]float64{center.Lng.Degrees(), center.Lat.Degrees()},
+		"areaKm2":   CellAreaKm2(cellID, EarthRadiusKm),
+		"labels": map[string]string{
+			"s2CellToken": cellToken,
+			// as a string, since JSON numbers lose precision past 2^53
+			"s2CellId": strconv.FormatUint(uint64(cell.ID()), 10),
+			"s2Level":  fmt.Sprintf("%d", cell.Level()),
+		},
+	}
+
+	feat.Geometry.Type = "Polygon"
+	feat.Geometry.Coordinates = [][][2]float64{cellRing(cell)}
+
+	return feat
+}
+
+// CellsToMultiPolygon returns a MultiPolygon geometry with one polygon
+// outlining each of cellIDs, in the same order, or nil if there are no
+// cells.
+func CellsToMultiPolygon(cellIDs []s2.CellID) *GeoJSONGeometry {
+	if len(cellIDs) == 0 {
+		return nil
+	}
+
+	polys := make([][][][2]float64, len(cellIDs))
+	for i, cellID := range cellIDs {
+		polys[i] = [][][2]float64{cellRing(s2.CellFromCellID(cellID))}
+	}
+
+	return &GeoJSONGeometry{Type: "MultiPolygon", Coordinates: polys}
+}
+
+// cellRing returns the closed ring of [lng, lat] positions outlining c
+func cellRing(c s2.Cell) [][2]float64 {
+	// have to reverse the order of lat/lng per GeoJSON
+	var coords [][2]float64
+	for _, point := range EdgesOfCell(c) {
+		coords = append(coords, [2]float64{point[1], point[0]})
+	}
+	return coords
+}
+
+// EdgesOfCell returns the closed ring of [lat, lng] vertices of c, wound
+// counter-clockwise as RFC 7946 requires of exterior rings.
+func EdgesOfCell(c s2.Cell) [][2]float64 {
+	var edges [][2]float64
+	for i := 0; i < 4; i++ {
+		latLng := s2.LatLngFromPoint(c.Vertex(i))
+		edges = append(edges, [2]float64{latLng.Lat.Degrees(), latLng.Lng.Degrees()})
+	}
+
+	// s2 vertices are counter-clockwise on the sphere, but that doesn't
+	// always survive projection to lat/lng
+	if ringSignedArea(edges) < 0 {
+		for i, j := 0, len(edges)-1; i < j; i, j = i+1, j-1 {
+			edges[i], edges[j] = edges[j], edges[i]
+		}
+	}
+
+	// need to close the loop
+	edges = append(edges, edges[0])
+
+	return edges
+}
+
+// ringSignedArea returns the planar signed area of an open ring of
+// [lat, lng] vertices, positive when counter-clockwise. Longitudes are
+// unwrapped relative to the first vertex so rings crossing the
+// antimeridian keep their orientation.
+func ringSignedArea(ring [][2]float64) float64 {
+	unwrap := func(lng float64) float64 {
+		for lng-ring[0][1] > 180 {
+			lng -= 360
+		}
+		for lng-ring[0][1] < -180 {
+			lng += 360
+		}
+		return lng
+	}
+
+	var area float64
+	for i := range ring {
+		a, b := ring[i], ring[(i+1)%len(ring)]
+		area += unwrap(a[1])*b[0] - unwrap(b[1])*a[0]
+	}
+	return area / 2
+}
+
+// ReadCellTokens reads one cell token per line from r, skipping blank
+// lines. Lines that aren't valid tokens are returned separately in invalid
+// rather than failing the whole read.
+func ReadCellTokens(r io.Reader) (cellIDs []s2.CellID, invalid []string, err error) {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		token := strings.TrimSpace(scanner.Text())
+		if token == "" {
+			continue
+		}
+
+		cellID := s2.CellIDFromToken(token)
+		if !cellID.IsValid() {
+			invalid = append(invalid, token)
+			continue
+		}
+		cellIDs = append(cellIDs, cellID)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	return cellIDs, invalid, nil
+}
+
+// CellsToTokens returns the token of each cell, in the same order.
+func CellsToTokens(cellIDs []s2.CellID) []string {
+	tokens := make([]string, len(cellIDs))
+	for i, cellID := range cellIDs {
+		tokens[i] = cellID.ToToken()
+	}
+	return tokens
+}
+
+// CellSummary is the compact JSON form of a cell.
+type CellSummary struct {
+	Token string `json:"token"`
+	Level int    `json:"level"`
+}
+
+// CellsToSummaries returns the token and level of each cell, in the same
+// order.
+func CellsToSummaries(cellIDs []s2.CellID) []CellSummary {
+	summaries := make([]CellSummary, len(cellIDs))
+	for i, cellID := range cellIDs {
+		summaries[i] = CellSummary{Token: cellID.ToToken(), Level: cellID.Level()}
+	}
+	return summaries
+}
@@ -0,0 +1,239 @@
+package geokit
+
+import (
+	"bytes"
+	"encoding/json"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/golang/geo/s2"
+)
+
+func TestEdgesOfCell(t *testing.T) {
+	for _, tt := range []struct {
+		name     string
+		lat, lng float64
+		level    int
+	}{
+		{"seattle", 47.6, -122.3, 10},
+		{"north pole face", 80, 30, 6},
+		{"south pole face", -80, -30, 6},
+		{"west of the antimeridian", 10, 179.99, 4},
+		{"east of the antimeridian", 10, -179.99, 4},
+		{"face cell", 0, 0, 0},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			cell := s2.CellFromCellID(s2.CellIDFromLatLng(s2.LatLngFromDegrees(tt.lat, tt.lng)).Parent(tt.level))
+			edges := EdgesOfCell(cell)
+
+			if len(edges) != 5 {
+				t.Fatalf("got %d vertices, want 5", len(edges))
+			}
+			if edges[0] != edges[4] {
+				t.Errorf("ring isn't closed: %v", edges)
+			}
+			if area := ringSignedArea(edges[:4]); area <= 0 {
+				t.Errorf("got signed area %v, want a counter-clockwise ring", area)
+			}
+		})
+	}
+}
+
+func TestCellToGeoJSONFeature(t *testing.T) {
+	cellID := s2.CellIDFromLatLng(s2.LatLngFromDegrees(47.6, -122.3)).Parent(12)
+	feat := CellToGeoJSONFeature(cellID)
+
+	if feat.ID != cellID.ToToken() {
+		t.Errorf("got id %v, want %v", feat.ID, cellID.ToToken())
+	}
+	if got := feat.Properties["entity_id"]; got != cellID.ToToken() {
+		t.Errorf("got entity_id %v, want %v", got, cellID.ToToken())
+	}
+
+	labels := feat.Properties["labels"].(map[string]string)
+	want := map[string]string{"s2CellToken": cellID.ToToken(), "s2CellId": strconv.FormatUint(uint64(cellID), 10), "s2Level": "12"}
+	if !reflect.DeepEqual(labels, want) {
+		t.Errorf("got labels %v, want %v", labels, want)
+	}
+
+	center := s2.LatLngFromPoint(cellID.Point())
+	if got := feat.Properties["center"].([2]float64); math.Abs(got[0]-center.Lng.Degrees()) > 1e-9 || math.Abs(got[1]-center.Lat.Degrees()) > 1e-9 {
+		t.Errorf("got center %v, want %v", got, center)
+	}
+
+	ring := feat.Geometry.Coordinates.([][][2]float64)[0]
+	poly, err := GeoJSONPolygonToS2Polygon(&GeoJSONPolygonGeometry{Coordinates: [][][2]float64{ring}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !poly.ContainsPoint(cellID.Point()) {
+		t.Error("outline does not contain the cell's center")
+	}
+	if got, want := poly.Area(), s2.CellFromCellID(cellID).ExactArea(); math.Abs(got-want) > 1e-6*want {
+		t.Errorf("outline has area %v, want %v", got, want)
+	}
+}
+
+func TestCellsBBox(t *testing.T) {
+	for _, tt := range []struct {
+		name     string
+		lat, lng []float64
+		want     func(bbox []float64) bool
+	}{
+		{"no cells", nil, nil, func(bbox []float64) bool { return bbox == nil }},
+		{
+			name: "one cell",
+			lat:  []float64{47.6},
+			lng:  []float64{-122.3},
+			want: func(bbox []float64) bool {
+				return bbox[0] < -122.3 && bbox[1] < 47.6 && bbox[2] > -122.3 && bbox[3] > 47.6
+			},
+		},
+		{
+			// west is greater than east
+			name: "across the antimeridian",
+			lat:  []float64{10, 10},
+			lng:  []float64{179.9, -179.9},
+			want: func(bbox []float64) bool {
+				return bbox[0] > 179 && bbox[2] < -179 && bbox[1] < 10 && bbox[3] > 10
+			},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			var cellIDs []s2.CellID
+			for i := range tt.lat {
+				cellIDs = append(cellIDs, s2.CellIDFromLatLng(s2.LatLngFromDegrees(tt.lat[i], tt.lng[i])).Parent(12))
+			}
+			if bbox := CellsBBox(cellIDs); !tt.want(bbox) {
+				t.Errorf("got bbox %v", bbox)
+			}
+		})
+	}
+}
+
+func TestCellsToMultiPolygon(t *testing.T) {
+	if geo := CellsToMultiPolygon(nil); geo != nil {
+		t.Errorf("got %v for no cells, want nil", geo)
+	}
+
+	parent := s2.CellIDFromLatLng(s2.LatLngFromDegrees(47.6, -122.3)).Parent(10)
+	children := parent.Children()
+	geo := CellsToMultiPolygon(children[:])
+	polys := geo.Coordinates.([][][][2]float64)
+	if len(polys) != 4 {
+		t.Fatalf("got %d polygons, want 4", len(polys))
+	}
+	for i, poly := range polys {
+		if want := cellRing(s2.CellFromCellID(children[i])); !reflect.DeepEqual(poly[0], want) {
+			t.Errorf("polygon %d: got %v, want the outline of %s", i, poly[0], children[i].ToToken())
+		}
+	}
+}
+
+func TestReadCellTokens(t *testing.T) {
+	cellID := s2.CellIDFromLatLng(s2.LatLngFromDegrees(47.6, -122.3)).Parent(12)
+
+	for _, tt := range []struct {
+		name    string
+		in      string
+		want    []s2.CellID
+		invalid []string
+	}{
+		{"empty", "", nil, nil},
+		{"one token", cellID.ToToken() + "\n", []s2.CellID{cellID}, nil},
+		{"blank lines and spaces", "\n  " + cellID.ToToken() + "  \n\n", []s2.CellID{cellID}, nil},
+		{"invalid token", cellID.ToToken() + "\nnope\n", []s2.CellID{cellID}, []string{"nope"}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			cellIDs, invalid, err := ReadCellTokens(strings.NewReader(tt.in))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(cellIDs, tt.want) {
+				t.Errorf("got cells %v, want %v", cellIDs, tt.want)
+			}
+			if !reflect.DeepEqual(invalid, tt.invalid) {
+				t.Errorf("got invalid %v, want %v", invalid, tt.invalid)
+			}
+		})
+	}
+}
+
+func TestCellsToSummaries(t *testing.T) {
+	cellID := s2.CellIDFromLatLng(s2.LatLngFromDegrees(47.6, -122.3)).Parent(12)
+	cellIDs := []s2.CellID{cellID, cellID.Parent(4)}
+
+	want := []CellSummary{{Token: cellID.ToToken(), Level: 12}, {Token: cellID.Parent(4).ToToken(), Level: 4}}
+	if got := CellsToSummaries(cellIDs); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if got := CellsToTokens(cellIDs); !reflect.DeepEqual(got, []string{want[0].Token, want[1].Token}) {
+		t.Errorf("got tokens %v", got)
+	}
+}
+
+func TestWriteFeatureCollection(t *testing.T) {
+	cellID := s2.CellIDFromLatLng(s2.LatLngFromDegrees(47.6, -122.3)).Parent(12)
+
+	for _, tt := range []struct {
+		name    string
+		cellIDs []s2.CellID
+	}{
+		{"no cells", []s2.CellID{}},
+		{"one cell", []s2.CellID{cellID}},
+		{"several cells", []s2.CellID{cellID, cellID.Next(), cellID.Parent(4)}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := WriteFeatureCollection(&buf, tt.cellIDs); err != nil {
+				t.Fatal(err)
+			}
+
+			// streaming writes what encoding the whole collection would
+			var want bytes.Buffer
+			if err := json.NewEncoder(&want).Encode(CellsToGeoJSONFeatureCollection(tt.cellIDs)); err != nil {
+				t.Fatal(err)
+			}
+			if buf.String() != want.String() {
+				t.Errorf("got %s, want %s", buf.String(), want.String())
+			}
+
+			feats, err := DecodeGeoJSONFeatures(&buf)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(feats) != len(tt.cellIDs) {
+				t.Errorf("got %d features, want %d", len(feats), len(tt.cellIDs))
+			}
+		})
+	}
+}
+
+func TestStreamFeatureCollection(t *testing.T) {
+	feature := func(i int) GeoJSONFeature {
+		return GeoJSONFeature{Type: "Feature", ID: i, Geometry: GeoJSONGeometry{Type: "Point", Coordinates: [2]float64{float64(i), 0}}}
+	}
+
+	for _, tt := range []struct {
+		name string
+		bbox []float64
+		n    int
+		want string
+	}{
+		{"empty", nil, 0, `{"type":"FeatureCollection","features":[]}`},
+		{"bbox", []float64{0, 0, 1, 0}, 2, `{"type":"FeatureCollection","bbox":[0,0,1,0],"features":[{"type":"Feature","id":0,"properties":null,"geometry":{"type":"Point","coordinates":[0,0]}},{"type":"Feature","id":1,"properties":null,"geometry":{"type":"Point","coordinates":[1,0]}}]}`},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := StreamFeatureCollection(&buf, tt.bbox, tt.n, feature); err != nil {
+				t.Fatal(err)
+			}
+			if got := buf.String(); got != tt.want+"\n" {
+				t.Errorf("got %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,540 @@
+package geokit
+
+import (
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/golang/geo/s1"
+	"github.com/golang/geo/s2"
+)
+
+// Coverer holds the configuration for covering regions with cells. It is
+// safe for concurrent use, as every call builds its own s2.RegionCoverer.
+type Coverer struct {
+	MinLevel int
+	MaxLevel int
+	MaxCells int
+
+	// LevelMod restricts cells to levels MinLevel plus a multiple of
+	// LevelMod, which must be between 1 and 3. Zero is treated as 1.
+	LevelMod int
+
+	// TargetCellAreaKm2, if positive, sizes MaxCells for each polygon by
+	// its area, so that polygons large and small are covered at roughly
+	// the same resolution. MaxCells remains the upper limit.
+	TargetCellAreaKm2 float64
+
+	// Interior restricts coverings to cells fully contained by the region.
+	// Polygons are covered whole, holes included, so no cell overlaps a
+	// hole.
+	Interior bool
+
+	// IgnoreHoles covers polygons as if they had no interior rings.
+	IgnoreHoles bool
+
+	// AssumeLarge takes the exterior ring of every polygon to enclose more
+	// than a hemisphere, whatever its winding, so that polygons such as
+	// oceans aren't covered as their much smaller complement.
+	AssumeLarge bool
+
+	// BoundsOnly covers the bounding rectangle of each feature's geometry
+	// rather than the geometry itself, trading precision for speed.
+	BoundsOnly bool
+
+	// SnapLevel, if positive, moves every input vertex to the center of
+	// its cell at that level before covering, so inputs that differ by
+	// less than a cell produce the same covering.
+	SnapLevel int
+
+	// SimplifyToleranceKm, if positive, simplifies each polygon ring
+	// before covering, dropping vertices within this distance of the
+	// outline kept. See SimplifyRing.
+	SimplifyToleranceKm float64
+
+	// EarthRadiusKm, if positive, is the radius of the sphere on which
+	// TargetCellAreaKm2 and SimplifyToleranceKm are measured, in place of
+	// the EarthRadiusKm constant.
+	EarthRadiusKm float64
+
+	// FloodFillSeed, if set, covers each shape with the edge-connected
+	// cells at MaxLevel reachable from the cell containing the seed,
+	// leaving out any part of the shape not connected to it. Interior
+	// doesn't apply, and rather than coarsen cells to stay within
+	// MaxCells, the fill fails if it needs more. See FloodFill. Only
+	// polygons can hold the seed, so Points, lines and null geometries
+	// fail with ErrFloodFillSeedOutside.
+	FloodFillSeed *s2.LatLng
+
+	// Complement covers the part of each feature's bounding rectangle that
+	// lies outside its geometry. No returned cell touches the geometry.
+	Complement bool
+}
+
+// ErrFloodFillSeedOutside is returned when flood filling a shape that
+// doesn't contain the seed.
+var ErrFloodFillSeedOutside = errors.New("flood fill seed is not inside the shape")
+
+// CoverRegion returns the cells covering r. FloodFillSeed is ignored, as
+// flood fills can fail; see FloodFill.
+func (c *Coverer) CoverRegion(r s2.Region) []s2.CellID {
+	rc := &s2.RegionCoverer{MaxLevel: c.MaxLevel, MinLevel: c.MinLevel, LevelMod: c.LevelMod, MaxCells: c.MaxCells}
+
+	var covering s2.CellUnion
+	if c.Interior {
+		covering = rc.InteriorCovering(r)
+	} else {
+		covering = rc.Covering(r)
+	}
+
+	return []s2.CellID(covering)
+}
+
+// FloodFill returns the edge-connected cells at MaxLevel that intersect r
+// and are reachable from the cell containing FloodFillSeed. It fails with
+// ErrFloodFillSeedOutside if r doesn't contain the seed, and if the fill
+// needs more than MaxCells cells, as too fine a MaxLevel would.
+func (c *Coverer) FloodFill(r s2.Region) ([]s2.CellID, error) {
+	seed := s2.PointFromLatLng(*c.FloodFillSeed)
+	if !r.ContainsPoint(seed) {
+		return nil, ErrFloodFillSeedOutside
+	}
+
+	// s2.FloodFillRegionCovering has no limit, so fill here instead
+	start := s2.CellIDFromLatLng(*c.FloodFillSeed).Parent(c.pointLevel())
+	seen := map[s2.CellID]bool{start: true}
+	pending := []s2.CellID{start}
+	var cellIDs []s2.CellID
+	for len(pending) > 0 {
+		cellID := pending[len(pending)-1]
+		pending = pending[:len(pending)-1]
+		if !r.IntersectsCell(s2.CellFromCellID(cellID)) {
+			continue
+		}
+
+		if len(cellIDs) == c.MaxCells {
+			return nil, fmt.Errorf("flood fill needs more than %d cells at level %d", c.MaxCells, c.pointLevel())
+		}
+		cellIDs = append(cellIDs, cellID)
+
+		for _, neighbor := range cellID.EdgeNeighbors() {
+			if !seen[neighbor] {
+				seen[neighbor] = true
+				pending = append(pending, neighbor)
+			}
+		}
+	}
+
+	return cellIDs, nil
+}
+
+// CoverFeature returns the cells covering the geometry of f, which may be
+// a Point, LineString, MultiLineString, Polygon or MultiPolygon, so
+// collections mixing them need no special handling. Points are covered by
+// their containing cell at MaxLevel. Features with a null or empty geometry
+// have no cells.
+func (c *Coverer) CoverFeature(f *GeoJSONFeature) ([]s2.CellID, error) {
+	if f.Geometry.IsNull() {
+		if c.FloodFillSeed != nil {
+			return nil, ErrFloodFillSeedOutside
+		}
+		return nil, nil
+	}
+
+	geo, err := f.TypedGeometry()
+	if err != nil {
+		return nil, err
+	}
+
+	if pt, ok := geo.(*GeoJSONPointGeometry); ok {
+		if c.FloodFillSeed != nil {
+			return nil, ErrFloodFillSeedOutside
+		}
+		if c.Complement {
+			return nil, errors.New("unable to cover the complement of a Point")
+		}
+		positions := [][2]float64{pt.Coordinates}
+		if err := c.preparePositions(positions); err != nil {
+			return nil, err
+		}
+		pt.Coordinates = positions[0]
+		s2LatLng := s2.LatLngFromDegrees(pt.Coordinates[1], pt.Coordinates[0])
+		return []s2.CellID{CoverPoint(s2LatLng, c.pointLevel())}, nil
+	}
+
+	regions, err := c.featureRegions(geo)
+	if err != nil {
+		return nil, err
+	}
+	// an empty MultiPolygon or MultiLineString has no cells, like a null
+	// geometry, and can't hold a flood fill's seed
+	if len(regions) == 0 && c.FloodFillSeed == nil {
+		return nil, nil
+	}
+
+	if c.Complement {
+		if c.FloodFillSeed != nil {
+			return nil, errors.New("unable to flood fill the complement of a feature")
+		}
+		return c.coverComplement(regions), nil
+	}
+
+	// a flood fill covers only the shapes holding the seed, of which
+	// there must be at least one
+	var cellIDs []s2.CellID
+	seeded := false
+	for _, r := range regions {
+		shapeCells, err := c.coverShape(r)
+		if err == ErrFloodFillSeedOutside {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		seeded = true
+		cellIDs = append(cellIDs, shapeCells...)
+	}
+	if !seeded {
+		return nil, ErrFloodFillSeedOutside
+	}
+
+	return cellIDs, nil
+}
+
+// FeatureRegions returns the s2 regions CoverFeature covers for f, after
+// IgnoreHoles, SnapLevel, SimplifyToleranceKm and AssumeLarge have been
+// applied. Points and null geometries have none.
+func (c *Coverer) FeatureRegions(f *GeoJSONFeature) ([]s2.Region, error) {
+	if f.Geometry.IsNull() || f.Geometry.Type == "Point" {
+		return nil, nil
+	}
+
+	geo, err := f.TypedGeometry()
+	if err != nil {
+		return nil, err
+	}
+	return c.featureRegions(geo)
+}
+
+// featureRegions builds the s2 regions making up geo, returning nil if geo
+// is not a type made of regions
+func (c *Coverer) featureRegions(geo interface{}) ([]s2.Region, error) {
+	switch geo := geo.(type) {
+	case *GeoJSONPolygonGeometry:
+		s2Poly, err := c.buildPolygon(geo)
+		if err != nil {
+			return nil, err
+		}
+		return []s2.Region{s2Poly}, nil
+	case *GeoJSONMultiPolygonGeometry:
+		var regions []s2.Region
+		for i, rings := range geo.Coordinates {
+			s2Poly, err := c.buildPolygon(&GeoJSONPolygonGeometry{Type: "Polygon", Coordinates: rings})
+			if err != nil {
+				return nil, fmt.Errorf("polygon %d: %v", i, err)
+			}
+			regions = append(regions, s2Poly)
+		}
+		return regions, nil
+	case *GeoJSONLineStringGeometry:
+		if err := c.preparePositions(geo.Coordinates); err != nil {
+			return nil, err
+		}
+		return []s2.Region{GeoJSONLineStringToS2Polyline(geo)}, nil
+	case *GeoJSONMultiLineStringGeometry:
+		for i, line := range geo.Coordinates {
+			if err := c.preparePositions(line); err != nil {
+				return nil, fmt.Errorf("line %d: %v", i, err)
+			}
+		}
+
+		var regions []s2.Region
+		for _, s2Polyline := range GeoJSONMultiLineStringToS2Polylines(geo) {
+			regions = append(regions, s2Polyline)
+		}
+		return regions, nil
+	default:
+		return nil, nil
+	}
+}
+
+func (c *Coverer) buildPolygon(poly *GeoJSONPolygonGeometry) (*s2.Polygon, error) {
+	if c.IgnoreHoles && len(poly.Coordinates) > 1 {
+		poly.Coordinates = poly.Coordinates[:1]
+	}
+	for i, ring := range poly.Coordinates {
+		if err := c.preparePositions(ring); err != nil {
+			return nil, fmt.Errorf("ring %d: %v", i, err)
+		}
+		if c.SimplifyToleranceKm > 0 {
+			poly.Coordinates[i] = SimplifyRing(ring, s1.Angle(c.SimplifyToleranceKm/c.earthRadiusKm()))
+		}
+	}
+
+	s2Poly, err := geoJSONPolygonToS2Polygon(poly, c.AssumeLarge)
+	if err != nil && c.SnapLevel > 0 {
+		// snapping can collapse rings smaller than a cell
+		return nil, fmt.Errorf("%v after snapping to level %d", err, c.SnapLevel)
+	}
+	return s2Poly, err
+}
+
+// earthRadiusKm is the radius TargetCellAreaKm2 and SimplifyToleranceKm are
+// measured on
+func (c *Coverer) earthRadiusKm() float64 {
+	if c.EarthRadiusKm > 0 {
+		return c.EarthRadiusKm
+	}
+	return EarthRadiusKm
+}
+
+// pointLevel is the finest level at or below MaxLevel allowed by LevelMod,
+// matching the max level RegionCoverer would settle on
+func (c *Coverer) pointLevel() int {
+	if c.LevelMod <= 1 || c.MaxLevel < c.MinLevel {
+		return c.MaxLevel
+	}
+	return c.MaxLevel - (c.MaxLevel-c.MinLevel)%c.LevelMod
+}
+
+// preparePositions validates positions and, if SnapLevel is set, moves each
+// to the center of its cell at that level
+func (c *Coverer) preparePositions(positions [][2]float64) error {
+	if err := validatePositions(positions); err != nil {
+		return err
+	}
+	if c.SnapLevel <= 0 {
+		return nil
+	}
+
+	for i, pos := range positions {
+		cellID := s2.CellIDFromLatLng(s2.LatLngFromDegrees(pos[1], pos[0])).Parent(c.SnapLevel)
+		ll := cellID.LatLng()
+		positions[i] = [2]float64{ll.Lng.Degrees(), ll.Lat.Degrees()}
+	}
+	return nil
+}
+
+// coverShape covers r, or its bounding rectangle if BoundsOnly is set, by
+// flood fill if FloodFillSeed is set
+func (c *Coverer) coverShape(r s2.Region) ([]s2.CellID, error) {
+	shape := *c
+	if poly, ok := r.(*s2.Polygon); ok && c.TargetCellAreaKm2 > 0 && c.FloodFillSeed == nil {
+		shape.MaxCells = c.maxCellsForArea(poly.Area() * c.earthRadiusKm() * c.earthRadiusKm())
+	}
+
+	if c.BoundsOnly {
+		r = r.RectBound()
+	}
+	if c.FloodFillSeed != nil {
+		return shape.FloodFill(r)
+	}
+	return shape.CoverRegion(r), nil
+}
+
+// maxCellsForArea returns how many cells of TargetCellAreaKm2 fit in
+// areaKm2, between 1 and MaxCells
+func (c *Coverer) maxCellsForArea(areaKm2 float64) int {
+	n := math.Ceil(areaKm2 / c.TargetCellAreaKm2)
+	if n < 1 {
+		return 1
+	}
+	if n > float64(c.MaxCells) {
+		return c.MaxCells
+	}
+	return int(n)
+}
+
+// coverComplement covers the bounding rectangle of regions, less every cell
+// that touches one of them
+func (c *Coverer) coverComplement(regions []s2.Region) []s2.CellID {
+	// the cells being removed must reach all the way to the boundary, so
+	// they come from a regular covering even when Interior is set
+	shape := *c
+	shape.Interior = false
+
+	bound := s2.EmptyRect()
+	var shapeCells s2.CellUnion
+	for _, r := range regions {
+		bound = bound.Union(r.RectBound())
+		// without a flood fill, covering can't fail
+		cells, _ := shape.coverShape(r)
+		shapeCells = append(shapeCells, cells...)
+	}
+	shapeCells.Normalize()
+
+	boundCells := s2.CellUnion(c.CoverRegion(bound))
+	boundCells.Normalize()
+
+	return []s2.CellID(s2.CellUnionFromDifference(boundCells, shapeCells))
+}
+
+// Cover returns at most maxCells cells between minLevel and maxLevel that
+// cover r. If interior is true, only cells fully contained by r are returned.
+func Cover(r s2.Region, minLevel, maxLevel, maxCells int, interior bool) []s2.CellID {
+	c := Coverer{MinLevel: minLevel, MaxLevel: maxLevel, MaxCells: maxCells, Interior: interior}
+	return c.CoverRegion(r)
+}
+
+// AngleToKm returns the distance along the surface of a sphere of
+// earthRadiusKm, such as EarthRadiusKm, spanned by a.
+func AngleToKm(a s1.Angle, earthRadiusKm float64) float64 {
+	return a.Radians() * earthRadiusKm
+}
+
+// CapFromRadiusKm returns the cap of all points within radiusKm of center,
+// on a sphere of earthRadiusKm such as EarthRadiusKm.
+func CapFromRadiusKm(center s2.LatLng, radiusKm, earthRadiusKm float64) s2.Cap {
+	angle := s1.Angle(radiusKm / earthRadiusKm)
+	return s2.CapFromCenterAngle(s2.PointFromLatLng(center), angle)
+}
+
+// CoverPoint returns the single cell at level containing ll. Points have
+// no area, so RegionCoverer has nothing useful to say about them.
+func CoverPoint(ll s2.LatLng, level int) s2.CellID {
+	return s2.CellIDFromLatLng(ll).Parent(level)
+}
+
+// NormalizeCells sorts cellIDs, removes duplicates and replaces any cells
+// that tile a parent with that parent, so long as the parent is not coarser
+// than minLevel.
+func NormalizeCells(cellIDs []s2.CellID, minLevel int) []s2.CellID {
+	return NormalizeCellsLevelMod(cellIDs, minLevel, 1)
+}
+
+// NormalizeCellsLevelMod is like NormalizeCells, but also keeps every cell
+// at minLevel plus a multiple of levelMod. See Coverer.LevelMod.
+func NormalizeCellsLevelMod(cellIDs []s2.CellID, minLevel, levelMod int) []s2.CellID {
+	cu := s2.CellUnion(cellIDs)
+	cu.Normalize()
+
+	// Normalize ignores level constraints, so expand any parents it
+	// produced back out to minLevel and levelMod
+	if levelMod < 1 {
+		levelMod = 1
+	}
+	cu.Denormalize(minLevel, levelMod)
+
+	return []s2.CellID(cu)
+}
+
+// CoveringContainsPoint reports whether ll falls within any of cellIDs.
+func CoveringContainsPoint(cellIDs []s2.CellID, ll s2.LatLng) bool {
+	cu := s2.CellUnion(append([]s2.CellID(nil), cellIDs...))
+	cu.Normalize()
+	return cu.ContainsCellID(s2.CellIDFromLatLng(ll))
+}
+
+// CoveringContains reports whether the area of a includes all of the area
+// of b, e.g. whether a refined covering b stays within a coarse covering a.
+func CoveringContains(a, b []s2.CellID) bool {
+	x := s2.CellUnion(append([]s2.CellID(nil), a...))
+	x.Normalize()
+	y := s2.CellUnion(append([]s2.CellID(nil), b...))
+	y.Normalize()
+	return x.Contains(y)
+}
+
+// CoveringJaccard returns the Jaccard similarity of a and b by area: the
+// area both cover over the area either covers, 1 if neither covers any.
+// Comparing areas rather than cell IDs means coverings that split the same
+// area into cells of different sizes are still alike.
+func CoveringJaccard(a, b []s2.CellID) float64 {
+	x := s2.CellUnion(append([]s2.CellID(nil), a...))
+	x.Normalize()
+	y := s2.CellUnion(append([]s2.CellID(nil), b...))
+	y.Normalize()
+
+	union := s2.CellUnionFromUnion(x, y)
+	if len(union) == 0 {
+		return 1
+	}
+	intersection := s2.CellUnionFromIntersection(x, y)
+	return intersection.ExactArea() / union.ExactArea()
+}
+
+// NearestCellDistance returns the angular distance from ll to the nearest
+// point of any of cellIDs, which is zero if a cell contains ll. An infinite
+// angle is returned if there are no cells.
+func NearestCellDistance(cellIDs []s2.CellID, ll s2.LatLng) s1.Angle {
+	pt := s2.PointFromLatLng(ll)
+
+	nearest := s1.InfChordAngle()
+	for _, cellID := range cellIDs {
+		if d := s2.CellFromCellID(cellID).Distance(pt); d < nearest {
+			nearest = d
+		}
+	}
+
+	if nearest == s1.InfChordAngle() {
+		return s1.InfAngle()
+	}
+	return nearest.Angle()
+}
+
+// IntersectCells returns the cells covering the area common to a and b.
+func IntersectCells(a, b []s2.CellID) []s2.CellID {
+	x := s2.CellUnion(append([]s2.CellID(nil), a...))
+	x.Normalize()
+	y := s2.CellUnion(append([]s2.CellID(nil), b...))
+	y.Normalize()
+	return []s2.CellID(s2.CellUnionFromIntersection(x, y))
+}
+
+// SubtractCells returns the cells covering the area of a outside b.
+func SubtractCells(a, b []s2.CellID) []s2.CellID {
+	x := s2.CellUnion(append([]s2.CellID(nil), a...))
+	x.Normalize()
+	y := s2.CellUnion(append([]s2.CellID(nil), b...))
+	y.Normalize()
+	return []s2.CellID(s2.CellUnionFromDifference(x, y))
+}
+
+// CropCells returns the cells of cellIDs whose bounding rectangles
+// intersect rect, in the same order.
+func CropCells(cellIDs []s2.CellID, rect s2.Rect) []s2.CellID {
+	var cropped []s2.CellID
+	for _, cellID := range cellIDs {
+		if rect.Intersects(s2.CellFromCellID(cellID).RectBound()) {
+			cropped = append(cropped, cellID)
+		}
+	}
+	return cropped
+}
+
+// SearchMaxLevel returns the finest level between minLevel and maxLevel for
+// which count reports at most target cells, assuming count grows with the
+// level. If even minLevel exceeds target, minLevel is returned.
+func SearchMaxLevel(minLevel, maxLevel, target int, count func(level int) int) int {
+	lo, hi := minLevel, maxLevel
+	for lo < hi {
+		mid := (lo + hi + 1) / 2
+		if count(mid) <= target {
+			lo = mid
+		} else {
+			hi = mid - 1
+		}
+	}
+	return lo
+}
+
+// CellSources returns, for each of cellIDs, the indexes into
+// featureCellIDs of every feature covering that intersects the cell.
+func CellSources(cellIDs []s2.CellID, featureCellIDs [][]s2.CellID) [][]int {
+	featureUnions := make([]s2.CellUnion, len(featureCellIDs))
+	for i, featCellIDs := range featureCellIDs {
+		featureUnions[i] = s2.CellUnion(append([]s2.CellID(nil), featCellIDs...))
+		featureUnions[i].Normalize()
+	}
+
+	sources := make([][]int, len(cellIDs))
+	for i, cellID := range cellIDs {
+		for j := range featureUnions {
+			if featureUnions[j].IntersectsCellID(cellID) {
+				sources[i] = append(sources[i], j)
+			}
+		}
+	}
+
+	return sources
+}
@@ -0,0 +1,622 @@
+package geokit
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/golang/geo/s2"
+)
+
+// polygonFeature returns a Polygon feature with the given rings
+func polygonFeature(rings ...[][2]float64) GeoJSONFeature {
+	return GeoJSONFeature{
+		Type:     "Feature",
+		Geometry: GeoJSONGeometry{Type: "Polygon", Coordinates: rings},
+	}
+}
+
+// circleRing returns a closed ring of n positions around lng, lat, whose
+// radius in degrees wobbles so the outline isn't trivially simple
+func circleRing(lng, lat, radius float64, n int) [][2]float64 {
+	ring := make([][2]float64, 0, n+1)
+	for i := 0; i < n; i++ {
+		theta := 2 * math.Pi * float64(i) / float64(n)
+		r := radius * (1 + 0.1*math.Sin(12*theta))
+		ring = append(ring, [2]float64{lng + r*math.Cos(theta), lat + r*math.Sin(theta)})
+	}
+	return append(ring, ring[0])
+}
+
+// squareRing returns the closed, counter-clockwise ring of the square from
+// lng, lat to lng+size, lat+size
+func squareRing(lng, lat, size float64) [][2]float64 {
+	return [][2]float64{{lng, lat}, {lng + size, lat}, {lng + size, lat + size}, {lng, lat + size}, {lng, lat}}
+}
+
+// reversed returns ring wound the other way
+func reversed(ring [][2]float64) [][2]float64 {
+	out := make([][2]float64, len(ring))
+	for i, pos := range ring {
+		out[len(ring)-1-i] = pos
+	}
+	return out
+}
+
+func TestInteriorCoveringExcludesHole(t *testing.T) {
+	rings := [][][2]float64{squareRing(0, 0, 10), reversed(squareRing(4, 4, 2))}
+	poly, err := GeoJSONPolygonToS2Polygon(&GeoJSONPolygonGeometry{Coordinates: rings})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range []struct {
+		name  string
+		cover func() ([]s2.CellID, error)
+	}{
+		{"CoverFeature", func() ([]s2.CellID, error) {
+			feat := polygonFeature(rings...)
+			c := Coverer{MinLevel: 4, MaxLevel: 14, MaxCells: 500, Interior: true}
+			return c.CoverFeature(&feat)
+		}},
+		{"Cover", func() ([]s2.CellID, error) {
+			return Cover(poly, 4, 14, 500, true), nil
+		}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			cellIDs, err := tt.cover()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(cellIDs) == 0 {
+				t.Fatal("interior covering is empty")
+			}
+
+			holeCenter := s2.PointFromLatLng(s2.LatLngFromDegrees(5, 5))
+			for _, cellID := range cellIDs {
+				cell := s2.CellFromCellID(cellID)
+				// the polygon only contains cells that stay out of its hole
+				if !poly.ContainsCell(cell) {
+					t.Errorf("cell %s is not inside the holed polygon", cellID.ToToken())
+				}
+				if cell.ContainsPoint(holeCenter) {
+					t.Errorf("cell %s contains the center of the hole", cellID.ToToken())
+				}
+			}
+		})
+	}
+}
+
+func TestCoverFeatureIgnoreHoles(t *testing.T) {
+	rings := [][][2]float64{squareRing(0, 0, 10), reversed(squareRing(4, 4, 2))}
+
+	for _, tt := range []struct {
+		name        string
+		ignoreHoles bool
+		interior    bool
+		wantHole    bool
+	}{
+		{"holes kept", false, false, false},
+		{"holes ignored", true, false, true},
+		{"holes ignored in an interior covering", true, true, true},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			feat := polygonFeature(rings...)
+			c := Coverer{MinLevel: 4, MaxLevel: 10, MaxCells: 500, IgnoreHoles: tt.ignoreHoles, Interior: tt.interior}
+			cellIDs, err := c.CoverFeature(&feat)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := CoveringContainsPoint(cellIDs, s2.LatLngFromDegrees(5, 5)); got != tt.wantHole {
+				t.Errorf("got covering of the hole's center %v, want %v", got, tt.wantHole)
+			}
+			if !CoveringContainsPoint(cellIDs, s2.LatLngFromDegrees(1, 1)) {
+				t.Error("covering misses the polygon's interior")
+			}
+		})
+	}
+}
+
+func TestCoverFeatureBoundsOnly(t *testing.T) {
+	// the triangle leaves out the corner of its bounding box at 2, 2
+	triangle := [][2]float64{{0, 0}, {2, 0}, {0, 2}, {0, 0}}
+	line := [][2]float64{{0, 0}, {2, 2}}
+
+	for _, tt := range []struct {
+		name       string
+		geo        GeoJSONGeometry
+		boundsOnly bool
+		corner     [2]float64
+		wantCorner bool
+	}{
+		{"polygon", GeoJSONGeometry{Type: "Polygon", Coordinates: [][][2]float64{triangle}}, false, [2]float64{1.9, 1.9}, false},
+		{"polygon bounds", GeoJSONGeometry{Type: "Polygon", Coordinates: [][][2]float64{triangle}}, true, [2]float64{1.9, 1.9}, true},
+		{"line", GeoJSONGeometry{Type: "LineString", Coordinates: line}, false, [2]float64{1.9, 0.1}, false},
+		{"line bounds", GeoJSONGeometry{Type: "LineString", Coordinates: line}, true, [2]float64{1.9, 0.1}, true},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			feat := GeoJSONFeature{Type: "Feature", Geometry: tt.geo}
+			c := Coverer{MinLevel: 4, MaxLevel: 12, MaxCells: 500, BoundsOnly: tt.boundsOnly}
+			cellIDs, err := c.CoverFeature(&feat)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := CoveringContainsPoint(cellIDs, s2.LatLngFromDegrees(tt.corner[1], tt.corner[0])); got != tt.wantCorner {
+				t.Errorf("got covering of %v %v, want %v", tt.corner, got, tt.wantCorner)
+			}
+		})
+	}
+}
+
+func TestCoverFeatureMixedCollection(t *testing.T) {
+	const doc = `{"type":"FeatureCollection","features":[
+		{"type":"Feature","properties":{},"geometry":{"type":"Point","coordinates":[1,1]}},
+		{"type":"Feature","properties":{},"geometry":{"type":"LineString","coordinates":[[0,0],[1,1],[2,0]]}},
+		{"type":"Feature","properties":{},"geometry":{"type":"MultiLineString","coordinates":[[[0,0],[1,0]],[[5,5],[6,5]]]}},
+		{"type":"Feature","properties":{},"geometry":{"type":"Polygon","coordinates":[[[0,0],[1,0],[1,1],[0,1],[0,0]]]}},
+		{"type":"Feature","properties":{},"geometry":{"type":"MultiPolygon","coordinates":[[[[0,0],[1,0],[1,1],[0,1],[0,0]]],[[[5,5],[6,5],[6,6],[5,6],[5,5]]]]}},
+		{"type":"Feature","properties":{},"geometry":null}
+	]}`
+	feats, err := DecodeGeoJSONFeatures(strings.NewReader(doc))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// every feature is covered by the same call, and must cover these
+	for i, want := range [][][2]float64{
+		{{1, 1}},
+		{{0, 0}, {2, 0}},
+		{{0, 0}, {6, 5}},
+		{{0.5, 0.5}},
+		{{0.5, 0.5}, {5.5, 5.5}},
+		nil,
+	} {
+		name := feats[i].Geometry.Type
+		if feats[i].Geometry.IsNull() {
+			name = "null"
+		}
+		t.Run(name, func(t *testing.T) {
+			c := Coverer{MinLevel: 4, MaxLevel: 12, MaxCells: 100}
+			cellIDs, err := c.CoverFeature(&feats[i])
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want == nil && len(cellIDs) != 0 {
+				t.Errorf("got %d cells for a null geometry, want none", len(cellIDs))
+			}
+			for _, pos := range want {
+				if !CoveringContainsPoint(cellIDs, s2.LatLngFromDegrees(pos[1], pos[0])) {
+					t.Errorf("covering does not contain %v", pos)
+				}
+			}
+		})
+	}
+}
+
+func TestCoverFeatureSnapLevel(t *testing.T) {
+	ring := circleRing(-122.3, 47.6, 0.1, 50)
+
+	// copies of ring whose vertices move frac of the way toward the
+	// centers of their level 18 cells, without leaving them
+	jittered := func(frac float64) [][2]float64 {
+		out := make([][2]float64, len(ring))
+		for i, pos := range ring {
+			center := s2.LatLngFromPoint(s2.CellIDFromLatLng(s2.LatLngFromDegrees(pos[1], pos[0])).Parent(18).Point())
+			out[i] = [2]float64{
+				pos[0] + frac*(center.Lng.Degrees()-pos[0]),
+				pos[1] + frac*(center.Lat.Degrees()-pos[1]),
+			}
+		}
+		return out
+	}
+
+	for _, tt := range []struct {
+		name      string
+		snapLevel int
+		same      bool
+	}{
+		{"not snapped", 0, false},
+		{"snapped", 18, true},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			c := Coverer{MinLevel: 4, MaxLevel: 24, MaxCells: 500, SnapLevel: tt.snapLevel}
+			f := polygonFeature(ring)
+			want, err := c.CoverFeature(&f)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for _, frac := range []float64{0.5, 0.9} {
+				f := polygonFeature(jittered(frac))
+				got, err := c.CoverFeature(&f)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if same := reflect.DeepEqual(got, want); same != tt.same {
+					t.Errorf("moved %v of the way: got the same covering %v, want %v", frac, same, tt.same)
+				}
+			}
+		})
+	}
+}
+
+func TestCoverFeatureEmptyMulti(t *testing.T) {
+	for _, typ := range []string{"MultiPolygon", "MultiLineString"} {
+		t.Run(typ, func(t *testing.T) {
+			feats, err := DecodeGeoJSONFeatures(strings.NewReader(`{"type":"FeatureCollection","features":[{"type":"Feature","geometry":{"type":"` + typ + `","coordinates":[]}}]}`))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			c := Coverer{MinLevel: 4, MaxLevel: 12, MaxCells: 100}
+			if cellIDs, err := c.CoverFeature(&feats[0]); err != nil || len(cellIDs) != 0 {
+				t.Errorf("got %d cells and error %v, want none", len(cellIDs), err)
+			}
+
+			seed := s2.LatLngFromDegrees(0, 0)
+			c.FloodFillSeed = &seed
+			if _, err := c.CoverFeature(&feats[0]); err != ErrFloodFillSeedOutside {
+				t.Errorf("got error %v flood filling, want %v", err, ErrFloodFillSeedOutside)
+			}
+		})
+	}
+}
+
+func BenchmarkCoverPolygon(b *testing.B) {
+	// roughly a county: a quarter degree across with a thousand vertices
+	feat := polygonFeature(circleRing(-122.3, 47.6, 0.25, 1000))
+
+	for _, levels := range [][2]int{{4, 10}, {4, 14}, {8, 18}} {
+		b.Run(fmt.Sprintf("levels=%d-%d", levels[0], levels[1]), func(b *testing.B) {
+			c := Coverer{MinLevel: levels[0], MaxLevel: levels[1], MaxCells: 1000}
+			for i := 0; i < b.N; i++ {
+				if _, err := c.CoverFeature(&feat); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func TestFloodFill(t *testing.T) {
+	square, err := GeoJSONPolygonToS2Polygon(&GeoJSONPolygonGeometry{Coordinates: [][][2]float64{squareRing(0, 0, 1)}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range []struct {
+		name     string
+		seed     s2.LatLng
+		maxCells int
+		wantErr  error
+	}{
+		{"inside", s2.LatLngFromDegrees(0.5, 0.5), 1000, nil},
+		{"seed outside", s2.LatLngFromDegrees(5, 5), 1000, ErrFloodFillSeedOutside},
+		{"needs more cells", s2.LatLngFromDegrees(0.5, 0.5), 10, errors.New("flood fill needs more than 10 cells at level 8")},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			c := Coverer{MinLevel: 8, MaxLevel: 8, MaxCells: tt.maxCells, FloodFillSeed: &tt.seed}
+			cellIDs, err := c.FloodFill(square)
+			if tt.wantErr != nil {
+				if err == nil || err.Error() != tt.wantErr.Error() {
+					t.Errorf("got error %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// the same cells a covering at that level would have
+			got := append([]s2.CellID(nil), cellIDs...)
+			sort.Slice(got, func(i, j int) bool { return got[i] < got[j] })
+			if want := Cover(square, 8, 8, 1000, false); !reflect.DeepEqual(got, want) {
+				t.Errorf("got cells %v, want those of a covering, %v", got, want)
+			}
+			for _, cellID := range cellIDs {
+				if cellID.Level() != 8 {
+					t.Errorf("cell %s is at level %d, want 8", cellID.ToToken(), cellID.Level())
+				}
+			}
+		})
+	}
+}
+
+func TestCoverFeatureFloodFill(t *testing.T) {
+	// far enough apart that no cell touches both squares
+	feat := GeoJSONFeature{Type: "Feature", Geometry: GeoJSONGeometry{
+		Type:        "MultiPolygon",
+		Coordinates: [][][][2]float64{{squareRing(0, 0, 1)}, {squareRing(3, 0, 1)}},
+	}}
+
+	for _, tt := range []struct {
+		name    string
+		seed    s2.LatLng
+		inside  [2]float64
+		outside [2]float64
+		wantErr error
+	}{
+		{name: "first polygon", seed: s2.LatLngFromDegrees(0.5, 0.5), inside: [2]float64{0.9, 0.9}, outside: [2]float64{3.5, 0.5}},
+		{name: "second polygon", seed: s2.LatLngFromDegrees(0.5, 3.5), inside: [2]float64{3.1, 0.1}, outside: [2]float64{0.5, 0.5}},
+		{name: "between them", seed: s2.LatLngFromDegrees(0.5, 2), wantErr: ErrFloodFillSeedOutside},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			f := feat
+			c := Coverer{MinLevel: 4, MaxLevel: 8, MaxCells: 1000, FloodFillSeed: &tt.seed}
+			cellIDs, err := c.CoverFeature(&f)
+			if err != tt.wantErr {
+				t.Fatalf("got error %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if !CoveringContainsPoint(cellIDs, s2.LatLngFromDegrees(tt.inside[1], tt.inside[0])) {
+				t.Errorf("fill does not reach %v", tt.inside)
+			}
+			if CoveringContainsPoint(cellIDs, s2.LatLngFromDegrees(tt.outside[1], tt.outside[0])) {
+				t.Errorf("fill reaches the other polygon at %v", tt.outside)
+			}
+		})
+	}
+
+	// nothing but polygons can hold the seed
+	for _, geo := range []GeoJSONGeometry{
+		{Type: "Point", Coordinates: [2]float64{0.5, 0.5}},
+		{Type: "LineString", Coordinates: [][2]float64{{0, 0.5}, {1, 0.5}}},
+		{},
+	} {
+		seed := s2.LatLngFromDegrees(0.5, 0.5)
+		c := Coverer{MinLevel: 4, MaxLevel: 8, MaxCells: 1000, FloodFillSeed: &seed}
+		if _, err := c.CoverFeature(&GeoJSONFeature{Type: "Feature", Geometry: geo}); err != ErrFloodFillSeedOutside {
+			t.Errorf("%q: got error %v, want %v", geo.Type, err, ErrFloodFillSeedOutside)
+		}
+	}
+
+	seed := s2.LatLngFromDegrees(0.5, 0.5)
+	c := Coverer{MinLevel: 4, MaxLevel: 8, MaxCells: 1000, FloodFillSeed: &seed, Complement: true}
+	if _, err := c.CoverFeature(&feat); err == nil {
+		t.Error("got no error flood filling a complement")
+	}
+}
+
+func TestCoverFeatureComplement(t *testing.T) {
+	rings := [][][2]float64{{{0, 0}, {2, 0}, {0, 2}, {0, 0}}}
+	feat := polygonFeature(rings...)
+	c := Coverer{MinLevel: 4, MaxLevel: 12, MaxCells: 500, Complement: true}
+	cellIDs, err := c.CoverFeature(&feat)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cellIDs) == 0 {
+		t.Fatal("complement is empty")
+	}
+
+	poly, err := GeoJSONPolygonToS2Polygon(&GeoJSONPolygonGeometry{Coordinates: rings})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, cellID := range cellIDs {
+		if poly.IntersectsCell(s2.CellFromCellID(cellID)) {
+			t.Errorf("cell %s touches the triangle", cellID.ToToken())
+		}
+	}
+	if !CoveringContainsPoint(cellIDs, s2.LatLngFromDegrees(1.9, 1.9)) {
+		t.Error("complement misses the far corner of the bounding box")
+	}
+
+	point := GeoJSONFeature{Type: "Feature", Geometry: GeoJSONGeometry{Type: "Point", Coordinates: []interface{}{1.0, 1.0}}}
+	if _, err := c.CoverFeature(&point); err == nil {
+		t.Error("got no error covering the complement of a point")
+	}
+}
+
+func TestCoverFeatureLevelMod(t *testing.T) {
+	feat := polygonFeature(circleRing(-122.3, 47.6, 0.25, 100))
+	point := GeoJSONFeature{Type: "Feature", Geometry: GeoJSONGeometry{Type: "Point", Coordinates: []interface{}{-122.3, 47.6}}}
+
+	for _, levelMod := range []int{1, 2, 3} {
+		t.Run(fmt.Sprintf("levelMod=%d", levelMod), func(t *testing.T) {
+			c := Coverer{MinLevel: 4, MaxLevel: 12, MaxCells: 200, LevelMod: levelMod}
+			for _, f := range []GeoJSONFeature{feat, point} {
+				cellIDs, err := c.CoverFeature(&f)
+				if err != nil {
+					t.Fatal(err)
+				}
+				for _, cellID := range cellIDs {
+					if l := cellID.Level(); l < 4 || l > 12 || (l-4)%levelMod != 0 {
+						t.Errorf("%s: cell %s is at level %d", f.Geometry.Type, cellID.ToToken(), l)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestNormalizeCellsLevelMod(t *testing.T) {
+	parent := s2.CellIDFromLatLng(s2.LatLngFromDegrees(47.6, -122.3)).Parent(8)
+	children := parent.Children()
+
+	for _, tt := range []struct {
+		name               string
+		minLevel, levelMod int
+		want               int
+	}{
+		{"merged into the parent", 8, 1, 1},
+		{"parent too coarse", 9, 1, 4},
+		{"parent off the level mod", 7, 2, 4},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			got := NormalizeCellsLevelMod(append([]s2.CellID(nil), children[:]...), tt.minLevel, tt.levelMod)
+			if len(got) != tt.want {
+				t.Errorf("got %d cells, want %d", len(got), tt.want)
+			}
+			if !CoveringContains(got, children[:]) || !CoveringContains(children[:], got) {
+				t.Error("normalizing changed the area covered")
+			}
+		})
+	}
+}
+
+func TestCoveringContains(t *testing.T) {
+	square, err := GeoJSONPolygonToS2Polygon(&GeoJSONPolygonGeometry{Coordinates: [][][2]float64{squareRing(0, 0, 2)}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	coarse := Cover(square, 4, 8, 50, false)
+	fine := Cover(square, 4, 14, 500, false)
+
+	for _, tt := range []struct {
+		name string
+		a, b []s2.CellID
+		want bool
+	}{
+		{"coarse contains fine", coarse, fine, true},
+		{"fine doesn't contain coarse", fine, coarse, false},
+		{"itself", fine, fine, true},
+		{"another face", fine, []s2.CellID{s2.CellIDFromFace(3)}, false},
+		{"nothing", fine, nil, true},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := CoveringContains(tt.a, tt.b); got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCoveringJaccard(t *testing.T) {
+	square, err := GeoJSONPolygonToS2Polygon(&GeoJSONPolygonGeometry{Coordinates: [][][2]float64{squareRing(0, 0, 2)}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	fine := Cover(square, 4, 14, 500, false)
+	children := s2.CellIDFromFace(0).Children()
+
+	for _, tt := range []struct {
+		name string
+		a, b []s2.CellID
+		want float64
+	}{
+		{"identical", fine, fine, 1},
+		// the same area split into cells of different sizes
+		{"normalized", []s2.CellID{s2.CellIDFromFace(0)}, children[:], 1},
+		{"both empty", nil, nil, 1},
+		{"one empty", fine, nil, 0},
+		{"disjoint", []s2.CellID{s2.CellIDFromFace(0)}, []s2.CellID{s2.CellIDFromFace(1)}, 0},
+		{"quarter", []s2.CellID{s2.CellIDFromFace(0)}, []s2.CellID{s2.CellIDFromFace(0).ChildBegin()}, 0.25},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := CoveringJaccard(tt.a, tt.b); math.Abs(got-tt.want) > 1e-9 {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIntersectCells(t *testing.T) {
+	face := []s2.CellID{s2.CellIDFromFace(0)}
+	child := []s2.CellID{s2.CellIDFromFace(0).ChildBegin()}
+	if got := IntersectCells(face, child); !reflect.DeepEqual(got, child) {
+		t.Errorf("got intersection %v, want %v", got, child)
+	}
+	if got := IntersectCells(face, []s2.CellID{s2.CellIDFromFace(1)}); len(got) != 0 {
+		t.Errorf("got intersection %v of disjoint coverings, want none", got)
+	}
+}
+
+func TestSubtractCells(t *testing.T) {
+	face := []s2.CellID{s2.CellIDFromFace(0)}
+	children := s2.CellIDFromFace(0).Children()
+	if got := SubtractCells(face, children[:1]); !reflect.DeepEqual(got, children[1:]) {
+		t.Errorf("got difference %v, want the other three children", got)
+	}
+	if got := SubtractCells(face, face); len(got) != 0 {
+		t.Errorf("got difference %v of a covering less itself, want none", got)
+	}
+}
+
+func TestCropCells(t *testing.T) {
+	cellIDs := []s2.CellID{
+		s2.CellIDFromLatLng(s2.LatLngFromDegrees(0.5, 0.5)).Parent(10),
+		s2.CellIDFromLatLng(s2.LatLngFromDegrees(40, 40)).Parent(10),
+		s2.CellIDFromLatLng(s2.LatLngFromDegrees(0.7, 0.2)).Parent(10),
+	}
+	rect, err := ParseBBox("0,0,1,1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := CropCells(cellIDs, rect)
+	if len(got) != 2 || got[0] != cellIDs[0] || got[1] != cellIDs[2] {
+		t.Errorf("got %v, want the first and last cells in order", got)
+	}
+}
+
+func TestNearestCellDistance(t *testing.T) {
+	cellID := s2.CellIDFromLatLng(s2.LatLngFromDegrees(0, 0)).Parent(10)
+
+	if d := NearestCellDistance([]s2.CellID{cellID}, s2.LatLngFromDegrees(0, 0)); d != 0 {
+		t.Errorf("got %v inside the cell, want 0", d)
+	}
+	if d := AngleToKm(NearestCellDistance([]s2.CellID{cellID}, s2.LatLngFromDegrees(0, 1)), EarthRadiusKm); d < 100 || d > 112 {
+		t.Errorf("got %v km a degree away, want a little under 111", d)
+	}
+	if d := NearestCellDistance(nil, s2.LatLngFromDegrees(0, 0)); !math.IsInf(d.Radians(), 1) {
+		t.Errorf("got %v with no cells, want infinity", d)
+	}
+}
+
+func TestSearchMaxLevel(t *testing.T) {
+	// four times the cells per level, as coverings roughly grow
+	count := func(level int) int { return 1 << uint(2*level) }
+
+	for _, tt := range []struct {
+		name   string
+		target int
+		want   int
+	}{
+		{"exact", 1 << 10, 5},
+		{"between levels", 1<<10 + 1, 5},
+		{"below min", 1, 2},
+		{"above max", 1 << 40, 12},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := SearchMaxLevel(2, 12, tt.target, count); got != tt.want {
+				t.Errorf("got level %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCellSources(t *testing.T) {
+	parent := s2.CellIDFromLatLng(s2.LatLngFromDegrees(47.6, -122.3)).Parent(8)
+	children := parent.Children()
+	features := [][]s2.CellID{{children[0]}, {children[1], children[2]}, {parent}}
+
+	got := CellSources([]s2.CellID{children[0], children[2], parent, parent.Next()}, features)
+	want := [][]int{{0, 2}, {1, 2}, {0, 1, 2}, nil}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestMaxCellsForArea(t *testing.T) {
+	c := Coverer{MaxCells: 100, TargetCellAreaKm2: 10}
+	for _, tt := range []struct {
+		areaKm2 float64
+		want    int
+	}{
+		{0, 1},
+		{5, 1},
+		{25, 3},
+		{1000, 100},
+		{1e9, 100},
+	} {
+		if got := c.maxCellsForArea(tt.areaKm2); got != tt.want {
+			t.Errorf("%v km²: got %d cells, want %d", tt.areaKm2, got, tt.want)
+		}
+	}
+}
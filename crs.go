@@ -0,0 +1,152 @@
+package geokit
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strings"
+)
+
+// radius of the sphere Web Mercator projects from, which is the WGS84
+// semi-major axis rather than the mean radius
+const webMercatorRadiusM = 6378137.0
+
+// WebMercatorToLngLat converts EPSG:3857 x, y meters to WGS84 degrees.
+func WebMercatorToLngLat(x, y float64) (lng, lat float64) {
+	lng = x / webMercatorRadiusM * 180 / math.Pi
+	lat = (2*math.Atan(math.Exp(y/webMercatorRadiusM)) - math.Pi/2) * 180 / math.Pi
+	return lng, lat
+}
+
+// ReprojectFeatures converts the coordinates of feats in place from crs to
+// WGS84 lng/lat. Supported values of crs are epsg:4326, which leaves
+// coordinates untouched, and epsg:3857. Features with a crs member, as
+// pre-RFC 7946 GeoJSON allowed, are converted from the CRS it names
+// instead, and the member is removed.
+func ReprojectFeatures(feats []GeoJSONFeature, crs string) error {
+	crs = strings.ToLower(crs)
+	if !supportedCRS(crs) {
+		return fmt.Errorf("unsupported CRS %q", crs)
+	}
+
+	for i := range feats {
+		featCRS, err := featureCRS(&feats[i], crs)
+		if err != nil {
+			return fmt.Errorf("feature %d: %v", i, err)
+		}
+		delete(feats[i].Extra, "crs")
+		if featCRS == "epsg:4326" || feats[i].Geometry.IsNull() {
+			continue
+		}
+
+		coords, err := decodedCoordinates(feats[i].Geometry.Coordinates)
+		if err != nil {
+			return fmt.Errorf("feature %d: %v", i, err)
+		}
+
+		coords, err = mapPositions(coords, WebMercatorToLngLat)
+		if err != nil {
+			return fmt.Errorf("feature %d: %v", i, err)
+		}
+		feats[i].Geometry.Coordinates = coords
+	}
+
+	return nil
+}
+
+func supportedCRS(crs string) bool {
+	return crs == "epsg:4326" || crs == "epsg:3857"
+}
+
+// featureCRS returns the CRS named by the crs member of f, as epsg:<code>,
+// or fallback if it has none
+func featureCRS(f *GeoJSONFeature, fallback string) (string, error) {
+	raw, ok := f.Extra["crs"]
+	if !ok {
+		return fallback, nil
+	}
+
+	var member struct {
+		Type       string `json:"type"`
+		Properties struct {
+			Name string `json:"name"`
+		} `json:"properties"`
+	}
+	if err := json.Unmarshal(raw, &member); err != nil {
+		return "", fmt.Errorf("invalid crs member: %v", err)
+	}
+	if member.Type != "name" {
+		return "", fmt.Errorf("unsupported crs member type %q", member.Type)
+	}
+
+	// names come as a short EPSG:<code> or as an OGC URN such as
+	// urn:ogc:def:crs:EPSG::3857, whose version field may be empty
+	name := strings.ToLower(member.Properties.Name)
+	crs := name
+	if parts := strings.Split(name, ":"); len(parts) == 7 && strings.HasPrefix(name, "urn:ogc:def:crs:") {
+		crs = parts[4] + ":" + parts[6]
+	}
+	if crs == "ogc:crs84" {
+		crs = "epsg:4326"
+	}
+
+	if !supportedCRS(crs) {
+		return "", fmt.Errorf("unsupported CRS %q in crs member", member.Properties.Name)
+	}
+	return crs, nil
+}
+
+// decodedCoordinates returns coords in the shape encoding/json decodes
+// them to, nested []interface{} of float64. Coordinates built in Go rather
+// than decoded from JSON have concrete types, so are re-decoded.
+func decodedCoordinates(coords interface{}) (interface{}, error) {
+	if _, ok := coords.([]interface{}); ok {
+		return coords, nil
+	}
+
+	enc, err := json.Marshal(coords)
+	if err != nil {
+		return nil, err
+	}
+	var decoded interface{}
+	if err := json.Unmarshal(enc, &decoded); err != nil {
+		return nil, err
+	}
+	return decoded, nil
+}
+
+// mapPositions applies fn to every position in the decoded JSON coordinates
+// of any geometry type. Positions are the innermost arrays, whose elements
+// are numbers rather than further arrays.
+func mapPositions(coords interface{}, fn func(x, y float64) (float64, float64)) (interface{}, error) {
+	arr, ok := coords.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("coordinates must be arrays, got %v", coords)
+	}
+
+	if len(arr) > 0 {
+		if x, isNum := arr[0].(float64); isNum {
+			if len(arr) < 2 {
+				return nil, fmt.Errorf("position %v has fewer than 2 values", arr)
+			}
+			y, ok := arr[1].(float64)
+			if !ok {
+				return nil, fmt.Errorf("position %v must hold numbers", arr)
+			}
+
+			pos := append([]interface{}(nil), arr...)
+			pos[0], pos[1] = fn(x, y)
+			return pos, nil
+		}
+	}
+
+	mapped := make([]interface{}, len(arr))
+	for i, elem := range arr {
+		m, err := mapPositions(elem, fn)
+		if err != nil {
+			return nil, err
+		}
+		mapped[i] = m
+	}
+	return mapped, nil
+}
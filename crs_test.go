@@ -0,0 +1,111 @@
+package geokit
+
+import (
+	"encoding/json"
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestWebMercatorToLngLat(t *testing.T) {
+	for _, tt := range []struct {
+		name     string
+		x, y     float64
+		lng, lat float64
+	}{
+		{"origin", 0, 0, 0, 0},
+		{"antimeridian", 20037508.342789244, 0, 180, 0},
+		{"northern limit", 0, 20037508.342789244, 0, 85.0511287798066},
+		{"southern limit", 0, -20037508.342789244, 0, -85.0511287798066},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			lng, lat := WebMercatorToLngLat(tt.x, tt.y)
+			if math.Abs(lng-tt.lng) > 1e-6 || math.Abs(lat-tt.lat) > 1e-6 {
+				t.Errorf("got %v,%v, want %v,%v", lng, lat, tt.lng, tt.lat)
+			}
+		})
+	}
+}
+
+// unit squares at the origin, with %s standing in for any crs member
+const (
+	mercatorSquare = `{"type":"FeatureCollection","features":[{"type":"Feature","properties":{},%s"geometry":{"type":"Polygon","coordinates":[[[0,0],[111319.49079327357,0],[111319.49079327357,111325.14286638486],[0,111325.14286638486],[0,0]]]}}]}`
+	lngLatSquare   = `{"type":"FeatureCollection","features":[{"type":"Feature","properties":{},%s"geometry":{"type":"Polygon","coordinates":[[[0,0],[1,0],[1,1],[0,1],[0,0]]]}}]}`
+)
+
+// reprojectSquare reprojects the square in doc from crs, checking it comes
+// out as the unit square at the origin
+func reprojectSquare(t *testing.T, doc, crs, member, wantErr string) {
+	t.Helper()
+	feats, err := DecodeGeoJSONFeatures(strings.NewReader(strings.Replace(doc, "%s", member, 1)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = ReprojectFeatures(feats, crs)
+	if wantErr != "" {
+		if err == nil || !strings.Contains(err.Error(), wantErr) {
+			t.Errorf("got error %v, want one containing %q", err, wantErr)
+		}
+		return
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := feats[0].Extra["crs"]; ok {
+		t.Error("crs member was kept")
+	}
+
+	enc, err := json.Marshal(feats[0].Geometry.Coordinates)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var rings [][][2]float64
+	if err := json.Unmarshal(enc, &rings); err != nil {
+		t.Fatal(err)
+	}
+	for i, pos := range squareRing(0, 0, 1) {
+		got := rings[0][i]
+		if math.Abs(got[0]-pos[0]) > 1e-6 || math.Abs(got[1]-pos[1]) > 1e-6 {
+			t.Errorf("position %d: got %v, want %v", i, got, pos)
+		}
+	}
+}
+
+func TestReprojectFeatures(t *testing.T) {
+	for _, tt := range []struct {
+		name    string
+		doc     string
+		crs     string
+		wantErr string
+	}{
+		{name: "from web mercator", doc: mercatorSquare, crs: "EPSG:3857"},
+		{name: "already lng/lat", doc: lngLatSquare, crs: "epsg:4326"},
+		{name: "unsupported CRS", doc: lngLatSquare, crs: "epsg:27700", wantErr: `unsupported CRS "epsg:27700"`},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			reprojectSquare(t, tt.doc, tt.crs, "", tt.wantErr)
+		})
+	}
+}
+
+func TestReprojectFeaturesCRSMember(t *testing.T) {
+	for _, tt := range []struct {
+		name    string
+		doc     string
+		crs     string
+		member  string
+		wantErr string
+	}{
+		{name: "crs member", doc: mercatorSquare, crs: "epsg:4326", member: `"crs":{"type":"name","properties":{"name":"EPSG:3857"}},`},
+		{name: "crs member URN", doc: mercatorSquare, crs: "epsg:4326", member: `"crs":{"type":"name","properties":{"name":"urn:ogc:def:crs:EPSG::3857"}},`},
+		{name: "CRS84 member", doc: lngLatSquare, crs: "epsg:3857", member: `"crs":{"type":"name","properties":{"name":"urn:ogc:def:crs:OGC:1.3:CRS84"}},`},
+		{name: "unsupported crs member", doc: lngLatSquare, crs: "epsg:4326", member: `"crs":{"type":"name","properties":{"name":"EPSG:27700"}},`, wantErr: `feature 0: unsupported CRS "EPSG:27700" in crs member`},
+		{name: "linked crs member", doc: lngLatSquare, crs: "epsg:4326", member: `"crs":{"type":"link","properties":{"href":"x"}},`, wantErr: `unsupported crs member type "link"`},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			reprojectSquare(t, tt.doc, tt.crs, tt.member, tt.wantErr)
+		})
+	}
+}
@@ -0,0 +1,6 @@
+// Package geokit covers GeoJSON geometry with S2 cells.
+//
+// A Coverer turns a GeoJSON feature into the S2 cells covering it, and
+// CellsToGeoJSONFeatureCollection renders those cells back out as GeoJSON;
+// see the Coverer.CoverFeature example.
+package geokit
@@ -0,0 +1,36 @@
+package geokit_test
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/bcwaldon/geokit"
+)
+
+func ExampleCoverer_CoverFeature() {
+	feat := &geokit.GeoJSONFeature{
+		Type: "Feature",
+		Geometry: geokit.GeoJSONGeometry{
+			Type: "Polygon",
+			Coordinates: [][][2]float64{{
+				{-122.5, 37.7}, {-122.3, 37.7}, {-122.3, 37.8}, {-122.5, 37.8}, {-122.5, 37.7},
+			}},
+		},
+	}
+
+	c := geokit.Coverer{MinLevel: 8, MaxLevel: 10, MaxCells: 8}
+	cellIDs, err := c.CoverFeature(feat)
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println(strings.Join(geokit.CellsToTokens(cellIDs), " "))
+
+	// render the cells back out as GeoJSON
+	fc := geokit.CellsToGeoJSONFeatureCollection(cellIDs)
+	fmt.Println(len(fc.Features), "features")
+
+	// Output:
+	// 80857f 808581 808587 808f79 808f7d 808f7f 808f81 808f83
+	// 8 features
+}
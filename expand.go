@@ -0,0 +1,40 @@
+package geokit
+
+import (
+	"github.com/golang/geo/s2"
+)
+
+// ExpandCovering grows cellIDs by rings layers of neighboring cells. Each
+// layer adds every cell sharing an edge or vertex with the previous one, at
+// the level of the cell it borders, so a lone cell grows to a 3x3 block after
+// one ring. The result is normalized, which may merge parts of the block
+// into parent cells.
+func ExpandCovering(cellIDs []s2.CellID, rings int) []s2.CellID {
+	seen := make(map[s2.CellID]bool, len(cellIDs))
+	for _, cellID := range cellIDs {
+		seen[cellID] = true
+	}
+
+	// only cells added by the last ring can have neighbors not yet seen
+	frontier := append([]s2.CellID(nil), cellIDs...)
+	for r := 0; r < rings; r++ {
+		var next []s2.CellID
+		for _, cellID := range frontier {
+			for _, neighbor := range cellID.AllNeighbors(cellID.Level()) {
+				if !seen[neighbor] {
+					seen[neighbor] = true
+					next = append(next, neighbor)
+				}
+			}
+		}
+		frontier = next
+	}
+
+	cu := make(s2.CellUnion, 0, len(seen))
+	for cellID := range seen {
+		cu = append(cu, cellID)
+	}
+	cu.Normalize()
+
+	return []s2.CellID(cu)
+}
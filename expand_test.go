@@ -0,0 +1,51 @@
+package geokit
+
+import (
+	"testing"
+
+	"github.com/golang/geo/s2"
+)
+
+func TestExpandCovering(t *testing.T) {
+	cellID := s2.CellIDFromLatLng(s2.LatLngFromDegrees(47.6, -122.3)).Parent(12)
+	corner := s2.CellIDFromFace(0).ChildBeginAtLevel(6)
+
+	for _, tt := range []struct {
+		name    string
+		cellIDs []s2.CellID
+		rings   int
+		want    int
+	}{
+		{"no rings", []s2.CellID{cellID}, 0, 1},
+		{"one ring", []s2.CellID{cellID}, 1, 9},
+		{"two rings", []s2.CellID{cellID}, 2, 25},
+		// three faces meet at a cube corner, so the corner cell has only
+		// seven neighbors
+		{"cube corner", []s2.CellID{corner}, 1, 8},
+		{"no cells", nil, 3, 0},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ExpandCovering(tt.cellIDs, tt.rings)
+
+			// normalizing may merge parts of the block, so count at the
+			// cells' level
+			n := 0
+			for _, c := range got {
+				n += 1 << uint(2*(cellLevel(tt.cellIDs)-c.Level()))
+			}
+			if n != tt.want {
+				t.Errorf("got %d cells, want %d", n, tt.want)
+			}
+			if !CoveringContains(got, tt.cellIDs) {
+				t.Error("expanded covering lost the original cells")
+			}
+		})
+	}
+}
+
+func cellLevel(cellIDs []s2.CellID) int {
+	if len(cellIDs) == 0 {
+		return 0
+	}
+	return cellIDs[0].Level()
+}
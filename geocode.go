@@ -0,0 +1,186 @@
+package geokit
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"googlemaps.github.io/maps"
+)
+
+// GoogleMapsAPIKeyEnv is the environment variable consulted by
+// ResolveGoogleMapsAPIKey.
+const GoogleMapsAPIKeyEnv = "GOOGLE_MAPS_API_KEY"
+
+// ResolveGoogleMapsAPIKey returns override if set, falling back to the
+// value of the GOOGLE_MAPS_API_KEY environment variable.
+func ResolveGoogleMapsAPIKey(override string) string {
+	if override != "" {
+		return override
+	}
+	return os.Getenv(GoogleMapsAPIKeyEnv)
+}
+
+// ReadGoogleMapsCredentials returns the API key held in the api_key member
+// of the JSON credentials file at path.
+func ReadGoogleMapsCredentials(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	var creds struct {
+		APIKey string `json:"api_key"`
+	}
+	if err := json.NewDecoder(f).Decode(&creds); err != nil {
+		return "", fmt.Errorf("json decode failed: %v", err)
+	}
+	if creds.APIKey == "" {
+		return "", fmt.Errorf("no api_key in %s", path)
+	}
+
+	return creds.APIKey, nil
+}
+
+// Geocoder resolves addresses to candidate Point features, best match
+// first.
+type Geocoder interface {
+	Geocode(ctx context.Context, addr string) ([]GeoJSONFeature, error)
+}
+
+// MapsGeocoder is a Geocoder backed by the Google Maps Geocoding API.
+type MapsGeocoder struct {
+	client *maps.Client
+
+	// Region, if set, biases geocoding toward a region given as a ccTLD
+	// code, e.g. "uk".
+	Region string
+
+	// Language, if set, is the language results are returned in, e.g.
+	// "de".
+	Language string
+}
+
+// NewMapsGeocoder returns a MapsGeocoder authenticating with apiKey. If
+// qps is positive, requests are throttled to that many per second.
+func NewMapsGeocoder(apiKey string, qps int) (*MapsGeocoder, error) {
+	if apiKey == "" {
+		return nil, errors.New("missing Google Maps API key")
+	}
+
+	opts := []maps.ClientOption{maps.WithAPIKey(apiKey)}
+	if qps > 0 {
+		opts = append(opts, maps.WithRateLimit(qps))
+	}
+
+	cl, err := maps.NewClient(opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	return &MapsGeocoder{client: cl}, nil
+}
+
+// Geocode resolves addr to candidate Point features, best match first.
+// Each feature carries the queried address and the candidate's
+// formatted_address in its properties.
+func (g *MapsGeocoder) Geocode(ctx context.Context, addr string) ([]GeoJSONFeature, error) {
+	req := maps.GeocodingRequest{
+		Address:  addr,
+		Region:   g.Region,
+		Language: g.Language,
+	}
+	results, err := g.client.Geocode(ctx, &req)
+	if err != nil {
+		return nil, err
+	}
+
+	return geocodingResultsToFeatures(addr, results), nil
+}
+
+// ReverseGeocode resolves the given point to a formatted address. When
+// several addresses match, the first and most specific is returned. If no
+// address matches, an empty string is returned.
+func (g *MapsGeocoder) ReverseGeocode(ctx context.Context, lat, lng float64) (string, error) {
+	req := maps.GeocodingRequest{
+		LatLng:   &maps.LatLng{Lat: lat, Lng: lng},
+		Language: g.Language,
+	}
+	results, err := g.client.ReverseGeocode(ctx, &req)
+	if err != nil {
+		return "", err
+	}
+
+	if len(results) == 0 {
+		return "", nil
+	}
+
+	return results[0].FormattedAddress, nil
+}
+
+// Geocode resolves addr using the Google Maps Geocoding API. See
+// MapsGeocoder.Geocode.
+func Geocode(ctx context.Context, apiKey, addr string) ([]GeoJSONFeature, error) {
+	g, err := NewMapsGeocoder(apiKey, 0)
+	if err != nil {
+		return nil, err
+	}
+	return g.Geocode(ctx, addr)
+}
+
+// ReverseGeocode resolves the given point using the Google Maps Geocoding
+// API. See MapsGeocoder.ReverseGeocode.
+func ReverseGeocode(ctx context.Context, apiKey string, lat, lng float64) (string, error) {
+	g, err := NewMapsGeocoder(apiKey, 0)
+	if err != nil {
+		return "", err
+	}
+	return g.ReverseGeocode(ctx, lat, lng)
+}
+
+// ReadAddresses reads one address per line from r, skipping blank lines.
+func ReadAddresses(r io.Reader) ([]string, error) {
+	var addrs []string
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		addr := strings.TrimSpace(scanner.Text())
+		if addr == "" {
+			continue
+		}
+		addrs = append(addrs, addr)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return addrs, nil
+}
+
+func geocodingResultsToFeatures(addr string, results []maps.GeocodingResult) []GeoJSONFeature {
+	feats := make([]GeoJSONFeature, len(results))
+	for i, result := range results {
+		feats[i] = GeoJSONFeature{
+			Type: "Feature",
+			Geometry: GeoJSONGeometry{
+				Type: "Point",
+				Coordinates: [2]float64{
+					result.Geometry.Location.Lng,
+					result.Geometry.Location.Lat,
+				},
+			},
+			Properties: map[string]interface{}{
+				"address":           addr,
+				"formatted_address": result.FormattedAddress,
+			},
+		}
+	}
+	return feats
+}
@@ -0,0 +1,116 @@
+package geokit
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// CachingGeocoder wraps a Geocoder with a JSON cache on disk, keyed by
+// normalized address. Only lookups that return at least one candidate are
+// cached. New entries are only written out by Flush, so that a batch of
+// lookups rewrites the file once rather than once per address.
+type CachingGeocoder struct {
+	geocoder Geocoder
+	path     string
+
+	mu      sync.Mutex
+	entries map[string][]GeoJSONFeature
+	dirty   bool
+}
+
+// NewCachingGeocoder returns a CachingGeocoder backed by the file at path,
+// loading any entries it already holds. The file need not exist yet.
+func NewCachingGeocoder(g Geocoder, path string) (*CachingGeocoder, error) {
+	c := CachingGeocoder{
+		geocoder: g,
+		path:     path,
+		entries:  make(map[string][]GeoJSONFeature),
+	}
+
+	raw, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return &c, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("failed reading geocode cache: %v", err)
+	}
+
+	if err := json.Unmarshal(raw, &c.entries); err != nil {
+		return nil, fmt.Errorf("failed decoding geocode cache: %v", err)
+	}
+
+	return &c, nil
+}
+
+// Geocode returns the cached candidates for addr, falling through to the
+// wrapped Geocoder on a miss.
+func (c *CachingGeocoder) Geocode(ctx context.Context, addr string) ([]GeoJSONFeature, error) {
+	key := normalizeAddress(addr)
+
+	c.mu.Lock()
+	feats, ok := c.entries[key]
+	c.mu.Unlock()
+	if ok {
+		return feats, nil
+	}
+
+	feats, err := c.geocoder.Geocode(ctx, addr)
+	if err != nil || len(feats) == 0 {
+		return feats, err
+	}
+
+	c.mu.Lock()
+	c.entries[key] = feats
+	c.dirty = true
+	c.mu.Unlock()
+
+	return feats, nil
+}
+
+// Flush writes the cache to disk if it has entries not yet written. The
+// file is replaced by renaming a complete copy over it, so a crash midway
+// leaves the previous cache intact.
+func (c *CachingGeocoder) Flush() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !c.dirty {
+		return nil
+	}
+
+	enc, err := json.Marshal(c.entries)
+	if err != nil {
+		return fmt.Errorf("failed encoding geocode cache: %v", err)
+	}
+
+	tmp, err := ioutil.TempFile(filepath.Dir(c.path), filepath.Base(c.path)+".tmp*")
+	if err != nil {
+		return fmt.Errorf("failed writing geocode cache: %v", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	_, err = tmp.Write(enc)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), c.path)
+	}
+	if err != nil {
+		return fmt.Errorf("failed writing geocode cache: %v", err)
+	}
+
+	c.dirty = false
+	return nil
+}
+
+// lowercase and collapse whitespace so trivially different spellings of
+// the same address share an entry
+func normalizeAddress(addr string) string {
+	return strings.Join(strings.Fields(strings.ToLower(addr)), " ")
+}
@@ -0,0 +1,182 @@
+package geokit
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// stubGeocoder answers each lookup with the next of results, counting
+// calls, and repeats the last once they run out
+type stubGeocoder struct {
+	results []stubResult
+	calls   int
+}
+
+type stubResult struct {
+	feats []GeoJSONFeature
+	err   error
+}
+
+func (s *stubGeocoder) Geocode(ctx context.Context, addr string) ([]GeoJSONFeature, error) {
+	r := s.results[len(s.results)-1]
+	if s.calls < len(s.results) {
+		r = s.results[s.calls]
+	}
+	s.calls++
+	return r.feats, r.err
+}
+
+func pointFeature(lng, lat float64) GeoJSONFeature {
+	return GeoJSONFeature{Type: "Feature", Geometry: GeoJSONGeometry{Type: "Point", Coordinates: []interface{}{lng, lat}}}
+}
+
+func TestCachingGeocoder(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cache.json")
+	want := []GeoJSONFeature{pointFeature(-122.3, 47.6)}
+
+	stub := &stubGeocoder{results: []stubResult{{feats: want}}}
+	c, err := NewCachingGeocoder(stub, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, addr := range []string{"1 Main St, Seattle", "1  main st,   SEATTLE "} {
+		feats, err := c.Geocode(context.Background(), addr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(feats, want) {
+			t.Errorf("%q: got %v, want %v", addr, feats, want)
+		}
+	}
+	if stub.calls != 1 {
+		t.Errorf("got %d lookups, want 1", stub.calls)
+	}
+
+	// nothing is written until flushed
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatalf("cache was written before Flush: %v", err)
+	}
+	if err := c.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	// a fresh cache loads the entry rather than looking it up again
+	stub = &stubGeocoder{results: []stubResult{{}}}
+	c, err = NewCachingGeocoder(stub, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	feats, err := c.Geocode(context.Background(), "1 Main St, Seattle")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stub.calls != 0 {
+		t.Errorf("got %d lookups of a cached address, want 0", stub.calls)
+	}
+	if len(feats) != 1 || feats[0].Geometry.Type != "Point" {
+		t.Errorf("got %v from the cache file, want the cached point", feats)
+	}
+}
+
+func TestCachingGeocoderMisses(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cache.json")
+	stub := &stubGeocoder{results: []stubResult{{}}}
+	c, err := NewCachingGeocoder(stub, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// lookups without candidates aren't cached, so are tried every time
+	for i := 0; i < 2; i++ {
+		if _, err := c.Geocode(context.Background(), "nowhere"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if stub.calls != 2 {
+		t.Errorf("got %d lookups, want 2", stub.calls)
+	}
+
+	// and leave nothing to flush
+	if err := c.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("Flush wrote a cache without entries: %v", err)
+	}
+}
+
+func TestCachingGeocoderFlush(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "cache.json")
+	const old = `{"old address":[]}`
+	if err := os.WriteFile(path, []byte(old), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	stub := &stubGeocoder{results: []stubResult{{feats: []GeoJSONFeature{pointFeature(1, 2)}}}}
+	c, err := NewCachingGeocoder(stub, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Geocode(context.Background(), "new address"); err != nil {
+		t.Fatal(err)
+	}
+
+	// a directory in the way of the rename fails the flush, which must
+	// leave the old cache alone
+	if err := os.Rename(path, path+".bak"); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir(path, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(path, "keep"), nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Flush(); err == nil {
+		t.Fatal("got no error flushing over a directory")
+	}
+	if err := os.RemoveAll(path); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Rename(path+".bak", path); err != nil {
+		t.Fatal(err)
+	}
+	if raw, err := os.ReadFile(path); err != nil || string(raw) != old {
+		t.Errorf("got cache %q, %v after a failed flush, want %q", raw, err, old)
+	}
+
+	// once it succeeds both entries are there, and no temporary file is
+	// left behind
+	if err := c.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	reloaded, err := NewCachingGeocoder(stub, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(reloaded.entries) != 2 {
+		t.Errorf("got %d entries, want 2", len(reloaded.entries))
+	}
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 1 {
+		t.Errorf("got %d files next to the cache, want only the cache", len(files)-1)
+	}
+}
+
+func TestNewCachingGeocoderCorrupt(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cache.json")
+	if err := os.WriteFile(path, []byte("{"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewCachingGeocoder(&stubGeocoder{}, path); err == nil {
+		t.Error("got no error loading a corrupt cache")
+	}
+}
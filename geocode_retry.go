@@ -0,0 +1,73 @@
+package geokit
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"time"
+)
+
+// RetryingGeocoder wraps a Geocoder, retrying lookups that fail for
+// reasons likely to pass, such as rate limiting or server errors. Waits
+// between attempts double each time.
+type RetryingGeocoder struct {
+	geocoder Geocoder
+	retries  int
+	backoff  time.Duration
+}
+
+// NewRetryingGeocoder returns a RetryingGeocoder that retries each lookup
+// up to retries times, waiting backoff before the first retry.
+func NewRetryingGeocoder(g Geocoder, retries int, backoff time.Duration) *RetryingGeocoder {
+	return &RetryingGeocoder{geocoder: g, retries: retries, backoff: backoff}
+}
+
+// Geocode resolves addr with the wrapped Geocoder, retrying retryable
+// failures until they run out or ctx is done. An attempt that ran out of
+// time while ctx has not, as one under its own timeout can, is retried
+// too.
+func (r *RetryingGeocoder) Geocode(ctx context.Context, addr string) ([]GeoJSONFeature, error) {
+	wait := r.backoff
+	for attempt := 0; ; attempt++ {
+		feats, err := r.geocoder.Geocode(ctx, addr)
+		if err == nil || attempt == r.retries {
+			return feats, err
+		}
+		timedOut := errors.Is(err, context.DeadlineExceeded) && ctx.Err() == nil
+		if !timedOut && !retryableGeocodeError(err) {
+			return feats, err
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, err
+		case <-time.After(wait):
+		}
+		wait *= 2
+	}
+}
+
+// retryableGeocodeError reports whether err looks transient. The maps
+// client reports API statuses as plain errors, and server errors as a
+// failure to decode the non-JSON body that comes with them.
+func retryableGeocodeError(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+
+	msg := err.Error()
+	if strings.Contains(msg, "OVER_QUERY_LIMIT") || strings.Contains(msg, "UNKNOWN_ERROR") {
+		return true
+	}
+
+	var syntaxErr *json.SyntaxError
+	if errors.As(err, &syntaxErr) || errors.Is(err, io.EOF) {
+		return true
+	}
+
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
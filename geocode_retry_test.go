@@ -0,0 +1,83 @@
+package geokit
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestRetryingGeocoder(t *testing.T) {
+	overLimit := errors.New("maps: OVER_QUERY_LIMIT - quota exceeded")
+	denied := errors.New("maps: REQUEST_DENIED - bad key")
+	found := stubResult{feats: []GeoJSONFeature{pointFeature(1, 2)}}
+
+	for _, tt := range []struct {
+		name    string
+		results []stubResult
+		retries int
+		calls   int
+		wantErr error
+	}{
+		{"first try", []stubResult{found}, 2, 1, nil},
+		{"fails twice, then succeeds", []stubResult{{err: overLimit}, {err: overLimit}, found}, 2, 3, nil},
+		{"runs out of retries", []stubResult{{err: overLimit}}, 2, 3, overLimit},
+		{"not retryable", []stubResult{{err: denied}, found}, 2, 1, denied},
+		{"no retries", []stubResult{{err: overLimit}, found}, 0, 1, overLimit},
+		// an attempt under its own timeout, as ctx is never done
+		{"attempt timed out", []stubResult{{err: context.DeadlineExceeded}, found}, 2, 2, nil},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			stub := &stubGeocoder{results: tt.results}
+			g := NewRetryingGeocoder(stub, tt.retries, time.Millisecond)
+
+			feats, err := g.Geocode(context.Background(), "1 Main St")
+			if err != tt.wantErr {
+				t.Fatalf("got error %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && len(feats) != 1 {
+				t.Errorf("got %d features, want 1", len(feats))
+			}
+			if stub.calls != tt.calls {
+				t.Errorf("got %d calls, want %d", stub.calls, tt.calls)
+			}
+		})
+	}
+}
+
+func TestRetryingGeocoderCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	stub := &stubGeocoder{results: []stubResult{{err: errors.New("UNKNOWN_ERROR")}}}
+	g := NewRetryingGeocoder(stub, 5, time.Hour)
+	if _, err := g.Geocode(ctx, "1 Main St"); err == nil {
+		t.Fatal("got no error")
+	}
+	if stub.calls != 1 {
+		t.Errorf("got %d calls after cancelation, want 1", stub.calls)
+	}
+}
+
+func TestRetryableGeocodeError(t *testing.T) {
+	var syntaxErr error = &json.SyntaxError{}
+	for _, tt := range []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"over query limit", errors.New("maps: OVER_QUERY_LIMIT - "), true},
+		{"unknown error", errors.New("maps: UNKNOWN_ERROR - "), true},
+		{"non-JSON server error", syntaxErr, true},
+		{"request denied", errors.New("maps: REQUEST_DENIED - "), false},
+		{"canceled", context.Canceled, false},
+		{"deadline", context.DeadlineExceeded, false},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := retryableGeocodeError(tt.err); got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,182 @@
+package geokit
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"googlemaps.github.io/maps"
+)
+
+func TestResolveGoogleMapsAPIKey(t *testing.T) {
+	t.Setenv(GoogleMapsAPIKeyEnv, "from-env")
+	if got := ResolveGoogleMapsAPIKey("override"); got != "override" {
+		t.Errorf("got %q, want the override", got)
+	}
+	if got := ResolveGoogleMapsAPIKey(""); got != "from-env" {
+		t.Errorf("got %q, want the environment's key", got)
+	}
+}
+
+func TestReadGoogleMapsCredentials(t *testing.T) {
+	dir := t.TempDir()
+	for _, tt := range []struct {
+		name    string
+		content string
+		want    string
+		wantErr string
+	}{
+		{"api key", `{"api_key":"secret"}`, "secret", ""},
+		{"no api key", `{"client_id":"x"}`, "", "no api_key in"},
+		{"not JSON", `api_key=secret`, "", "json decode failed"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(dir, strings.ReplaceAll(tt.name, " ", "_")+".json")
+			if err := os.WriteFile(path, []byte(tt.content), 0o600); err != nil {
+				t.Fatal(err)
+			}
+
+			key, err := ReadGoogleMapsCredentials(path)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("got error %v, want one containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if key != tt.want {
+				t.Errorf("got %q, want %q", key, tt.want)
+			}
+		})
+	}
+
+	if _, err := ReadGoogleMapsCredentials(filepath.Join(dir, "missing.json")); err == nil {
+		t.Error("got no error reading a missing file")
+	}
+}
+
+func TestReadAddresses(t *testing.T) {
+	addrs, err := ReadAddresses(strings.NewReader("1 Main St\n\n  2 Pine St  \n\t\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"1 Main St", "2 Pine St"}; !reflect.DeepEqual(addrs, want) {
+		t.Errorf("got %q, want %q", addrs, want)
+	}
+}
+
+func TestGeocodingResultsToFeatures(t *testing.T) {
+	var results []maps.GeocodingResult
+	for _, loc := range []maps.LatLng{{Lat: 47.6, Lng: -122.3}, {Lat: 45.5, Lng: -122.7}} {
+		var r maps.GeocodingResult
+		r.Geometry.Location = loc
+		r.FormattedAddress = "somewhere"
+		results = append(results, r)
+	}
+
+	feats := geocodingResultsToFeatures("1 Main St", results)
+	if len(feats) != 2 {
+		t.Fatalf("got %d features, want 2", len(feats))
+	}
+	for i, feat := range feats {
+		if want := [2]float64{results[i].Geometry.Location.Lng, results[i].Geometry.Location.Lat}; feat.Geometry.Coordinates != want {
+			t.Errorf("feature %d: got %v, want [lng, lat] %v", i, feat.Geometry.Coordinates, want)
+		}
+		if feat.Properties["address"] != "1 Main St" || feat.Properties["formatted_address"] != "somewhere" {
+			t.Errorf("feature %d: got properties %v", i, feat.Properties)
+		}
+	}
+}
+
+func TestMapsGeocoderRequest(t *testing.T) {
+	var got url.Values
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.URL.Query()
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `{"status":"OK","results":[{"formatted_address":"1 Main St","geometry":{"location":{"lat":47.6,"lng":-122.3}}}]}`)
+	}))
+	defer srv.Close()
+
+	cl, err := maps.NewClient(maps.WithAPIKey("key"), maps.WithBaseURL(srv.URL))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range []struct {
+		name             string
+		region, language string
+	}{
+		{"defaults", "", ""},
+		{"region and language", "uk", "de"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			g := &MapsGeocoder{client: cl, Region: tt.region, Language: tt.language}
+
+			feats, err := g.Geocode(context.Background(), "1 main st")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(feats) != 1 {
+				t.Fatalf("got %d features, want 1", len(feats))
+			}
+			if got.Get("address") != "1 main st" || got.Get("region") != tt.region || got.Get("language") != tt.language {
+				t.Errorf("got geocoding query %v", got)
+			}
+
+			if _, err := g.ReverseGeocode(context.Background(), 47.6, -122.3); err != nil {
+				t.Fatal(err)
+			}
+			if got.Get("language") != tt.language {
+				t.Errorf("got reverse geocoding query %v, want language %q", got, tt.language)
+			}
+		})
+	}
+}
+
+func TestMapsGeocoderReverseGeocode(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		body string
+		want string
+	}{
+		{"no address", `{"status":"ZERO_RESULTS","results":[]}`, ""},
+		{"one address", `{"status":"OK","results":[{"formatted_address":"1 Main St"}]}`, "1 Main St"},
+		{"most specific first", `{"status":"OK","results":[{"formatted_address":"1 Main St"},{"formatted_address":"Seattle, WA"}]}`, "1 Main St"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			var latlng string
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				latlng = r.URL.Query().Get("latlng")
+				w.Header().Set("Content-Type", "application/json")
+				io.WriteString(w, tt.body)
+			}))
+			defer srv.Close()
+
+			cl, err := maps.NewClient(maps.WithAPIKey("key"), maps.WithBaseURL(srv.URL))
+			if err != nil {
+				t.Fatal(err)
+			}
+			g := &MapsGeocoder{client: cl}
+
+			got, err := g.ReverseGeocode(context.Background(), 47.6, -122.3)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+			if !strings.HasPrefix(latlng, "47.6") || !strings.Contains(latlng, "-122.3") {
+				t.Errorf("got latlng %q, want 47.6,-122.3", latlng)
+			}
+		})
+	}
+}
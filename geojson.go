@@ -0,0 +1,488 @@
+package geokit
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/golang/geo/s2"
+)
+
+// GeoJSONFeatureCollection is a GeoJSON FeatureCollection document.
+type GeoJSONFeatureCollection struct {
+	Type     string           `json:"type"`
+	BBox     []float64        `json:"bbox,omitempty"`
+	Features []GeoJSONFeature `json:"features"`
+
+	// CRS is the crs member of pre-RFC 7946 documents. See
+	// ReprojectFeatures.
+	CRS json.RawMessage `json:"crs,omitempty"`
+}
+
+// GeoJSONFeature is a single GeoJSON Feature with untyped geometry.
+type GeoJSONFeature struct {
+	Type string `json:"type"`
+
+	// ID is the feature's identifier, either a string or a number.
+	ID interface{} `json:"id,omitempty"`
+
+	Properties map[string]interface{} `json:"properties"`
+	Geometry   GeoJSONGeometry        `json:"geometry"`
+
+	// Extra holds any other members of the feature, such as bbox, keyed by
+	// name, so they survive decoding and encoding again untouched.
+	Extra map[string]json.RawMessage `json:"-"`
+}
+
+// the members GeoJSONFeature decodes itself
+var geoJSONFeatureMembers = []string{"type", "id", "properties", "geometry"}
+
+func isGeoJSONFeatureMember(name string) bool {
+	for _, member := range geoJSONFeatureMembers {
+		if name == member {
+			return true
+		}
+	}
+	return false
+}
+
+// UnmarshalJSON decodes a feature, keeping unknown members in Extra.
+func (f *GeoJSONFeature) UnmarshalJSON(b []byte) error {
+	// a distinct type keeps json.Unmarshal from recursing back into this
+	// method
+	type feature GeoJSONFeature
+	var plain feature
+	if err := json.Unmarshal(b, &plain); err != nil {
+		return err
+	}
+
+	var members map[string]json.RawMessage
+	if err := json.Unmarshal(b, &members); err != nil {
+		return err
+	}
+	for _, name := range geoJSONFeatureMembers {
+		delete(members, name)
+	}
+	if len(members) > 0 {
+		plain.Extra = members
+	}
+
+	*f = GeoJSONFeature(plain)
+	return nil
+}
+
+// MarshalJSON encodes a feature followed by the members in Extra, in name
+// order. Extra members named like the feature's own, such as geometry, are
+// dropped rather than encoded twice.
+func (f GeoJSONFeature) MarshalJSON() ([]byte, error) {
+	type feature GeoJSONFeature
+	enc, err := json.Marshal(feature(f))
+	if err != nil || len(f.Extra) == 0 {
+		return enc, err
+	}
+
+	names := make([]string, 0, len(f.Extra))
+	for name := range f.Extra {
+		if !isGeoJSONFeatureMember(name) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	// reopen the encoded object to append the extra members
+	buf := bytes.NewBuffer(enc[:len(enc)-1])
+	for _, name := range names {
+		key, err := json.Marshal(name)
+		if err != nil {
+			return nil, err
+		}
+		buf.WriteByte(',')
+		buf.Write(key)
+		buf.WriteByte(':')
+		buf.Write(f.Extra[name])
+	}
+	buf.WriteByte('}')
+
+	return buf.Bytes(), nil
+}
+
+// GeoJSONGeometry is a GeoJSON geometry whose coordinates have not yet
+// been decoded into a concrete type. See GeoJSONFeature.TypedGeometry.
+//
+// Positions may carry an altitude as a third value. The typed geometries
+// hold only [lng, lat], and encoding/json drops the extra values as it
+// decodes into them, so altitude is ignored when covering but kept in
+// Coordinates for output.
+type GeoJSONGeometry struct {
+	Type        string      `json:"type"`
+	Coordinates interface{} `json:"coordinates"`
+}
+
+// IsNull reports whether g came from a null geometry, which GeoJSON allows
+// for features with no location.
+func (g GeoJSONGeometry) IsNull() bool {
+	return g.Type == ""
+}
+
+// MarshalJSON encodes null geometries back to null.
+func (g GeoJSONGeometry) MarshalJSON() ([]byte, error) {
+	if g.IsNull() {
+		return []byte("null"), nil
+	}
+
+	// a distinct type keeps json.Marshal from recursing back into this method
+	type geometry GeoJSONGeometry
+	return json.Marshal(geometry(g))
+}
+
+// TypedGeometry decodes the feature's geometry into one of the concrete
+// geometry types, e.g. *GeoJSONPolygonGeometry.
+func (f *GeoJSONFeature) TypedGeometry() (interface{}, error) {
+	var geo interface{}
+	switch f.Geometry.Type {
+	case "Point":
+		geo = new(GeoJSONPointGeometry)
+	case "LineString":
+		geo = new(GeoJSONLineStringGeometry)
+	case "MultiLineString":
+		geo = new(GeoJSONMultiLineStringGeometry)
+	case "Polygon":
+		geo = new(GeoJSONPolygonGeometry)
+	case "MultiPolygon":
+		geo = new(GeoJSONMultiPolygonGeometry)
+	default:
+		return nil, fmt.Errorf("unsupported geometry %q", f.Geometry.Type)
+	}
+
+	enc, _ := json.Marshal(f.Geometry)
+	if err := json.Unmarshal(enc, geo); err != nil {
+		return nil, fmt.Errorf("failed decoding typed geometry: %v", err)
+	}
+
+	return geo, nil
+}
+
+// gzip streams always start with these two bytes
+var gzipMagic = []byte{0x1f, 0x8b}
+
+func maybeGunzip(r io.Reader) (io.Reader, error) {
+	br := bufio.NewReader(r)
+
+	magic, err := br.Peek(len(gzipMagic))
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	if !bytes.Equal(magic, gzipMagic) {
+		return br, nil
+	}
+
+	gr, err := gzip.NewReader(br)
+	if err != nil {
+		return nil, fmt.Errorf("gzip decode failed: %v", err)
+	}
+	return gr, nil
+}
+
+// CoveringLevels returns the s2MinLevel and s2MaxLevel properties of the
+// feature, falling back to minLevel and maxLevel when they are absent. The
+// levels returned are checked with ValidateLevels.
+func (f *GeoJSONFeature) CoveringLevels(minLevel, maxLevel int) (int, int, error) {
+	var err error
+	if minLevel, err = f.intProperty("s2MinLevel", minLevel); err != nil {
+		return 0, 0, err
+	}
+	if maxLevel, err = f.intProperty("s2MaxLevel", maxLevel); err != nil {
+		return 0, 0, err
+	}
+	if err := ValidateLevels(minLevel, maxLevel); err != nil {
+		return 0, 0, err
+	}
+	return minLevel, maxLevel, nil
+}
+
+func (f *GeoJSONFeature) intProperty(name string, fallback int) (int, error) {
+	val, ok := f.Properties[name]
+	if !ok {
+		return fallback, nil
+	}
+
+	// encoding/json decodes every JSON number as a float64
+	num, ok := val.(float64)
+	if !ok || num != float64(int(num)) {
+		return 0, fmt.Errorf("property %s must be an integer, got %v", name, val)
+	}
+
+	return int(num), nil
+}
+
+// GeoJSONPolygonGeometry is a GeoJSON Polygon. The first ring is the
+// exterior, any following rings are holes.
+type GeoJSONPolygonGeometry struct {
+	Type        string         `json:"type"`
+	Coordinates [][][2]float64 `json:"coordinates"`
+}
+
+// GeoJSONMultiPolygonGeometry is a GeoJSON MultiPolygon.
+type GeoJSONMultiPolygonGeometry struct {
+	Type        string           `json:"type"`
+	Coordinates [][][][2]float64 `json:"coordinates"`
+}
+
+// GeoJSONPointGeometry is a GeoJSON Point.
+type GeoJSONPointGeometry struct {
+	Type        string     `json:"type"`
+	Coordinates [2]float64 `json:"coordinates"`
+}
+
+// GeoJSONLineStringGeometry is a GeoJSON LineString.
+type GeoJSONLineStringGeometry struct {
+	Type        string       `json:"type"`
+	Coordinates [][2]float64 `json:"coordinates"`
+}
+
+// GeoJSONMultiLineStringGeometry is a GeoJSON MultiLineString.
+type GeoJSONMultiLineStringGeometry struct {
+	Type        string         `json:"type"`
+	Coordinates [][][2]float64 `json:"coordinates"`
+}
+
+// DecodeGeoJSONFeatures reads a GeoJSON FeatureCollection from r and
+// returns its features. Gzipped input is decompressed transparently. A crs
+// member of the collection is copied to every feature that lacks its own,
+// for ReprojectFeatures to honor.
+func DecodeGeoJSONFeatures(r io.Reader) ([]GeoJSONFeature, error) {
+	r, err := maybeGunzip(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var fc GeoJSONFeatureCollection
+
+	if err := json.NewDecoder(r).Decode(&fc); err != nil {
+		return nil, fmt.Errorf("json decode failed: %v", err)
+	}
+
+	if fc.Type != "FeatureCollection" {
+		return nil, fmt.Errorf("GeoJSON document type unsupported: %v", fc.Type)
+	}
+
+	if len(fc.CRS) > 0 {
+		for i := range fc.Features {
+			feat := &fc.Features[i]
+			if _, ok := feat.Extra["crs"]; ok {
+				continue
+			}
+			if feat.Extra == nil {
+				feat.Extra = make(map[string]json.RawMessage)
+			}
+			feat.Extra["crs"] = fc.CRS
+		}
+	}
+
+	return fc.Features, nil
+}
+
+// StreamFeatureCollection writes a FeatureCollection of n features to w,
+// calling feature for each index as it goes rather than holding every
+// feature in memory. The output matches encoding the equivalent
+// GeoJSONFeatureCollection.
+func StreamFeatureCollection(w io.Writer, bbox []float64, n int, feature func(i int) GeoJSONFeature) error {
+	if _, err := io.WriteString(w, `{"type":"FeatureCollection",`); err != nil {
+		return err
+	}
+
+	if len(bbox) > 0 {
+		enc, err := json.Marshal(bbox)
+		if err != nil {
+			return err
+		}
+		if _, err := fmt.Fprintf(w, `"bbox":%s,`, enc); err != nil {
+			return err
+		}
+	}
+
+	if _, err := io.WriteString(w, `"features":[`); err != nil {
+		return err
+	}
+
+	for i := 0; i < n; i++ {
+		if i > 0 {
+			if _, err := io.WriteString(w, ","); err != nil {
+				return err
+			}
+		}
+
+		enc, err := json.Marshal(feature(i))
+		if err != nil {
+			return err
+		}
+		if _, err := w.Write(enc); err != nil {
+			return err
+		}
+	}
+
+	_, err := io.WriteString(w, "]}\n")
+	return err
+}
+
+// GeoJSONPolygonToS2Polygon builds an s2.Polygon from all rings of poly,
+// returning an error identifying the first ring that is not a valid loop.
+// Rings may omit the closing position; it is dropped when present. Any
+// number of holes may follow the exterior ring, whatever their winding.
+func GeoJSONPolygonToS2Polygon(poly *GeoJSONPolygonGeometry) (*s2.Polygon, error) {
+	return geoJSONPolygonToS2Polygon(poly, false)
+}
+
+// GeoJSONLargePolygonToS2Polygon is GeoJSONPolygonToS2Polygon, but always
+// takes the exterior ring to enclose the larger of the two regions it
+// divides the sphere into, as polygons such as oceans do. Otherwise those
+// would be taken for their much smaller complement.
+func GeoJSONLargePolygonToS2Polygon(poly *GeoJSONPolygonGeometry) (*s2.Polygon, error) {
+	return geoJSONPolygonToS2Polygon(poly, true)
+}
+
+func geoJSONPolygonToS2Polygon(poly *GeoJSONPolygonGeometry, assumeLarge bool) (*s2.Polygon, error) {
+	var loops []*s2.Loop
+	for i, ring := range poly.Coordinates {
+		if err := validatePositions(ring); err != nil {
+			return nil, fmt.Errorf("ring %d: %v", i, err)
+		}
+
+		pts := ringToPoints(ring)
+		if len(pts) < 3 {
+			return nil, fmt.Errorf("ring %d has %d vertices, need at least 3", i, len(pts))
+		}
+
+		loop := s2.LoopFromPoints(pts)
+		if err := loop.Validate(); err != nil {
+			return nil, fmt.Errorf("ring %d is invalid: %v", i, err)
+		}
+
+		// PolygonFromLoops expects every loop to be counter-clockwise and
+		// works out the nesting itself. GeoJSON winds holes clockwise, and
+		// plenty of real files wind their exterior rings clockwise too, so
+		// invert any loop that encloses more than a hemisphere.
+		//
+		// Inverting also rights rings crossing the antimeridian. s2 points
+		// live on the sphere so the seam itself is harmless, but exporters
+		// that orient rings in planar lng/lat space get these backwards.
+		//
+		// Holes wound counter-clockwise, against RFC 7946, need nothing
+		// done: they're already counter-clockwise, and nesting alone makes
+		// them holes.
+		loop.Normalize()
+		if assumeLarge && i == 0 {
+			loop.Invert()
+		}
+
+		loops = append(loops, loop)
+	}
+	return s2.PolygonFromLoops(loops), nil
+}
+
+// LikelyLargeRing reports whether ring is wound counter-clockwise in
+// lng/lat, as RFC 7946 asks of exterior rings, yet encloses more than a
+// hemisphere on the sphere. GeoJSONPolygonToS2Polygon takes every ring to
+// bound the smaller region, as clockwise exteriors are common, but rings
+// like this one are more likely to describe something like an ocean.
+func LikelyLargeRing(ring [][2]float64) bool {
+	if validatePositions(ring) != nil {
+		return false
+	}
+	pts := ringToPoints(ring)
+	if len(pts) < 3 {
+		return false
+	}
+
+	// twice the signed planar area, positive for counter-clockwise
+	var area float64
+	for i, a := range ring {
+		b := ring[(i+1)%len(ring)]
+		area += a[0]*b[1] - b[0]*a[1]
+	}
+	return area > 0 && !s2.LoopFromPoints(pts).IsNormalized()
+}
+
+// GeoJSONLineStringToS2Polyline builds an s2.Polyline from line.
+func GeoJSONLineStringToS2Polyline(line *GeoJSONLineStringGeometry) *s2.Polyline {
+	return s2.PolylineFromLatLngs(positionsToLatLngs(line.Coordinates))
+}
+
+// GeoJSONMultiLineStringToS2Polylines builds one s2.Polyline per line
+// in lines.
+func GeoJSONMultiLineStringToS2Polylines(lines *GeoJSONMultiLineStringGeometry) []*s2.Polyline {
+	polylines := make([]*s2.Polyline, len(lines.Coordinates))
+	for i, line := range lines.Coordinates {
+		polylines[i] = s2.PolylineFromLatLngs(positionsToLatLngs(line))
+	}
+	return polylines
+}
+
+// validatePositions returns an error naming the first of positions that is
+// not a valid [lng, lat]. s2 accepts any numbers and quietly wraps them
+// into cells that have nothing to do with the input.
+func validatePositions(positions [][2]float64) error {
+	for i, pos := range positions {
+		if !s2.LatLngFromDegrees(pos[1], pos[0]).IsValid() {
+			return fmt.Errorf("vertex %d %v is out of range", i, pos)
+		}
+	}
+	return nil
+}
+
+// GeoJSON positions are [lng, lat]
+func positionsToLatLngs(positions [][2]float64) []s2.LatLng {
+	lls := make([]s2.LatLng, len(positions))
+	for i, pos := range positions {
+		lls[i] = s2.LatLngFromDegrees(pos[1], pos[0])
+	}
+	return lls
+}
+
+func positionsToPoints(positions [][2]float64) []s2.Point {
+	pts := make([]s2.Point, len(positions))
+	for i, ll := range positionsToLatLngs(positions) {
+		pts[i] = s2.PointFromLatLng(ll)
+	}
+	return pts
+}
+
+// GeoJSON rings repeat the first position as the last, and some exporters
+// repeat positions elsewhere too, all of which s2 would treat as degenerate
+// edges. Spikes that double back to the position before, as snapping tends
+// to leave behind, are degenerate too and confuse s2 about which side of the
+// ring is inside.
+func ringToPoints(ring [][2]float64) []s2.Point {
+	var deduped [][2]float64
+	for _, pos := range ring {
+		n := len(deduped)
+		if n > 0 && pos == deduped[n-1] {
+			continue
+		}
+		if n > 1 && pos == deduped[n-2] {
+			deduped = deduped[:n-1]
+			continue
+		}
+		deduped = append(deduped, pos)
+	}
+
+	// the same again where the ring wraps around
+	for len(deduped) > 2 {
+		n := len(deduped)
+		if deduped[0] == deduped[n-1] {
+			deduped = deduped[:n-1]
+		} else if deduped[1] == deduped[n-1] {
+			deduped = deduped[1 : n-1]
+		} else if deduped[0] == deduped[n-2] {
+			deduped = deduped[:n-2]
+		} else {
+			break
+		}
+	}
+
+	return positionsToPoints(deduped)
+}
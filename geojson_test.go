@@ -0,0 +1,398 @@
+package geokit
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/golang/geo/s2"
+)
+
+func TestGeoJSONPolygonToS2PolygonHoles(t *testing.T) {
+	for _, tt := range []struct {
+		name    string
+		rings   [][][2]float64
+		inside  [][2]float64
+		outside [][2]float64
+	}{
+		{
+			name: "two holes",
+			rings: [][][2]float64{
+				squareRing(0, 0, 10),
+				reversed(squareRing(2, 2, 2)),
+				reversed(squareRing(6, 6, 2)),
+			},
+			inside:  [][2]float64{{1, 1}, {5, 5}, {9, 1}},
+			outside: [][2]float64{{3, 3}, {7, 7}, {11, 11}},
+		},
+		{
+			// RFC 7946 winds holes clockwise, but nesting alone should do
+			name:    "counter-clockwise hole",
+			rings:   [][][2]float64{squareRing(0, 0, 10), squareRing(4, 4, 2)},
+			inside:  [][2]float64{{1, 1}, {9, 9}},
+			outside: [][2]float64{{5, 5}, {11, 11}},
+		},
+		{
+			name:    "clockwise exterior, counter-clockwise hole",
+			rings:   [][][2]float64{reversed(squareRing(0, 0, 10)), squareRing(4, 4, 2)},
+			inside:  [][2]float64{{1, 1}, {9, 9}},
+			outside: [][2]float64{{5, 5}, {11, 11}},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			poly, err := GeoJSONPolygonToS2Polygon(&GeoJSONPolygonGeometry{Coordinates: tt.rings})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := poly.NumLoops(); got != len(tt.rings) {
+				t.Fatalf("got %d loops, want %d", got, len(tt.rings))
+			}
+
+			for _, pos := range tt.inside {
+				if !poly.ContainsPoint(s2.PointFromLatLng(s2.LatLngFromDegrees(pos[1], pos[0]))) {
+					t.Errorf("polygon does not contain %v", pos)
+				}
+			}
+			for _, pos := range tt.outside {
+				if poly.ContainsPoint(s2.PointFromLatLng(s2.LatLngFromDegrees(pos[1], pos[0]))) {
+					t.Errorf("polygon contains %v", pos)
+				}
+			}
+
+			// fine enough that no cell spans a whole hole
+			feat := polygonFeature(tt.rings...)
+			c := Coverer{MinLevel: 8, MaxLevel: 12, MaxCells: 2000}
+			cellIDs, err := c.CoverFeature(&feat)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, pos := range tt.outside {
+				if CoveringContainsPoint(cellIDs, s2.LatLngFromDegrees(pos[1], pos[0])) {
+					t.Errorf("covering contains %v", pos)
+				}
+			}
+		})
+	}
+}
+
+func TestDecodeGeoJSONFeaturesAltitude(t *testing.T) {
+	const withAltitude = `{"type":"FeatureCollection","features":[{"type":"Feature","properties":{},"geometry":{"type":"Polygon","coordinates":[[[0,0,12.5],[1,0,13],[1,1,14],[0,1,13.5],[0,0,12.5]]]}}]}`
+	const without = `{"type":"FeatureCollection","features":[{"type":"Feature","properties":{},"geometry":{"type":"Polygon","coordinates":[[[0,0],[1,0],[1,1],[0,1],[0,0]]]}}]}`
+
+	var coverings [][]s2.CellID
+	for _, doc := range []string{withAltitude, without} {
+		feats, err := DecodeGeoJSONFeatures(strings.NewReader(doc))
+		if err != nil {
+			t.Fatal(err)
+		}
+		c := Coverer{MinLevel: 4, MaxLevel: 12, MaxCells: 50}
+		cellIDs, err := c.CoverFeature(&feats[0])
+		if err != nil {
+			t.Fatal(err)
+		}
+		coverings = append(coverings, cellIDs)
+	}
+
+	if !reflect.DeepEqual(coverings[0], coverings[1]) {
+		t.Errorf("altitude changed the covering: got %v, want %v", coverings[0], coverings[1])
+	}
+}
+
+func TestGeoJSONPolygonToS2PolygonWinding(t *testing.T) {
+	small, err := GeoJSONPolygonToS2Polygon(&GeoJSONPolygonGeometry{Coordinates: [][][2]float64{squareRing(0, 0, 1)}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range []struct {
+		name string
+		ring [][2]float64
+		want float64
+	}{
+		{"counter-clockwise", squareRing(0, 0, 1), small.Area()},
+		{"clockwise", reversed(squareRing(0, 0, 1)), small.Area()},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			poly, err := GeoJSONPolygonToS2Polygon(&GeoJSONPolygonGeometry{Coordinates: [][][2]float64{tt.ring}})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := poly.Area(); math.Abs(got-tt.want) > 1e-9 {
+				t.Errorf("got area %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGeoJSONPolygonToS2PolygonAntimeridian(t *testing.T) {
+	for _, tt := range []struct {
+		name  string
+		ring  [][2]float64
+		width float64
+	}{
+		{"counter-clockwise", [][2]float64{{179.5, 0}, {-179.5, 0}, {-179.5, 1}, {179.5, 1}, {179.5, 0}}, 1},
+		{"clockwise", [][2]float64{{179.5, 0}, {179.5, 1}, {-179.5, 1}, {-179.5, 0}, {179.5, 0}}, 1},
+		{"170E to 170W", [][2]float64{{170, 0}, {-170, 0}, {-170, 1}, {170, 1}, {170, 0}}, 20},
+		{"170E to 170W clockwise", [][2]float64{{170, 0}, {170, 1}, {-170, 1}, {-170, 0}, {170, 0}}, 20},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			poly, err := GeoJSONPolygonToS2Polygon(&GeoJSONPolygonGeometry{Coordinates: [][][2]float64{tt.ring}})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// the same shape away from the antimeridian
+			same, err := GeoJSONPolygonToS2Polygon(&GeoJSONPolygonGeometry{Coordinates: [][][2]float64{{{0, 0}, {tt.width, 0}, {tt.width, 1}, {0, 1}, {0, 0}}}})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got, want := poly.Area(), same.Area(); math.Abs(got-want) > 1e-9 {
+				t.Errorf("got area %v, want %v", got, want)
+			}
+
+			if !poly.ContainsPoint(s2.PointFromLatLng(s2.LatLngFromDegrees(0.5, 180))) {
+				t.Error("polygon does not contain the antimeridian")
+			}
+			if poly.ContainsPoint(s2.PointFromLatLng(s2.LatLngFromDegrees(0.5, 0))) {
+				t.Error("polygon contains the prime meridian")
+			}
+		})
+	}
+}
+
+func TestGeoJSONLargePolygonToS2Polygon(t *testing.T) {
+	// most of the globe, less a square around the origin
+	for _, ring := range [][][2]float64{reversed(squareRing(0, 0, 1)), squareRing(0, 0, 1)} {
+		poly, err := GeoJSONLargePolygonToS2Polygon(&GeoJSONPolygonGeometry{Coordinates: [][][2]float64{ring}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if poly.Area() < 2*math.Pi {
+			t.Errorf("got area %v, want more than a hemisphere", poly.Area())
+		}
+		if poly.ContainsPoint(s2.PointFromLatLng(s2.LatLngFromDegrees(0.5, 0.5))) {
+			t.Error("polygon contains the square it excludes")
+		}
+		if !poly.ContainsPoint(s2.PointFromLatLng(s2.LatLngFromDegrees(-40, 120))) {
+			t.Error("polygon does not contain the far side of the globe")
+		}
+	}
+}
+
+func TestLikelyLargeRing(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		ring [][2]float64
+		want bool
+	}{
+		{"small counter-clockwise", squareRing(0, 0, 1), false},
+		{"small clockwise", reversed(squareRing(0, 0, 1)), false},
+		{"ocean", [][2]float64{{-170, -80}, {170, -80}, {170, 80}, {-170, 80}, {-170, -80}}, true},
+		{"too short", [][2]float64{{0, 0}, {1, 1}}, false},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := LikelyLargeRing(tt.ring); got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRingToPoints(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		ring [][2]float64
+		want int
+	}{
+		{"repeated position", [][2]float64{{0, 0}, {1, 0}, {1, 0}, {1, 1}, {0, 1}, {0, 0}}, 4},
+		{"spike", [][2]float64{{0, 0}, {1, 0}, {2, 0.5}, {1, 0}, {1, 1}, {0, 1}, {0, 0}}, 4},
+		{"spike at the wrap", [][2]float64{{0, 0}, {1, 0}, {1, 1}, {0, 1}, {0, 0}, {-1, -1}, {0, 0}}, 4},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			pts := ringToPoints(tt.ring)
+			if len(pts) != tt.want {
+				t.Fatalf("got %d points, want %d", len(pts), tt.want)
+			}
+			if err := s2.LoopFromPoints(pts).Validate(); err != nil {
+				t.Errorf("loop is invalid: %v", err)
+			}
+		})
+	}
+}
+
+func TestRingToPointsClosingPosition(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		ring [][2]float64
+	}{
+		{"closed", squareRing(0, 0, 1)},
+		{"open", squareRing(0, 0, 1)[:4]},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			// the closing position repeats the first, so isn't a vertex
+			if pts := ringToPoints(tt.ring); len(pts) != 4 {
+				t.Errorf("got %d points, want 4", len(pts))
+			}
+		})
+	}
+}
+
+func TestGeoJSONPolygonToS2PolygonErrors(t *testing.T) {
+	for _, tt := range []struct {
+		name  string
+		rings [][][2]float64
+		want  string
+	}{
+		{"two positions", [][][2]float64{{{0, 0}, {1, 1}, {0, 0}}}, "ring 0 has 1 vertices, need at least 3"},
+		{"out of range", [][][2]float64{{{0, 0}, {1, 0}, {1, 91}, {0, 0}}}, "ring 0: vertex 2"},
+		{"bad hole", [][][2]float64{squareRing(0, 0, 10), {{1, 1}, {2, 2}}}, "ring 1 has 2 vertices, need at least 3"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := GeoJSONPolygonToS2Polygon(&GeoJSONPolygonGeometry{Coordinates: tt.rings})
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("got error %v, want one containing %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestCoveringLevels(t *testing.T) {
+	for _, tt := range []struct {
+		name     string
+		props    map[string]interface{}
+		min, max int
+		wantErr  bool
+	}{
+		{"no properties", nil, 4, 12, false},
+		{"both", map[string]interface{}{"s2MinLevel": 6.0, "s2MaxLevel": 8.0}, 6, 8, false},
+		{"max only", map[string]interface{}{"s2MaxLevel": 10.0}, 4, 10, false},
+		{"not an integer", map[string]interface{}{"s2MaxLevel": 10.5}, 0, 0, true},
+		{"not a number", map[string]interface{}{"s2MinLevel": "6"}, 0, 0, true},
+		{"past 30", map[string]interface{}{"s2MaxLevel": 31.0}, 0, 0, true},
+		{"min above max", map[string]interface{}{"s2MinLevel": 13.0}, 0, 0, true},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			f := GeoJSONFeature{Properties: tt.props}
+			min, max, err := f.CoveringLevels(4, 12)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("got levels %d-%d, want an error", min, max)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if min != tt.min || max != tt.max {
+				t.Errorf("got levels %d-%d, want %d-%d", min, max, tt.min, tt.max)
+			}
+		})
+	}
+}
+
+func TestDecodeGeoJSONFeatures(t *testing.T) {
+	for _, tt := range []struct {
+		name    string
+		doc     string
+		want    int
+		wantErr bool
+	}{
+		{"empty", `{"type":"FeatureCollection","features":[]}`, 0, false},
+		{"one feature", `{"type":"FeatureCollection","features":[{"type":"Feature","properties":{},"geometry":{"type":"Point","coordinates":[1,2]}}]}`, 1, false},
+		{"not a collection", `{"type":"Feature","properties":{},"geometry":null}`, 0, true},
+		{"not JSON", `{`, 0, true},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			feats, err := DecodeGeoJSONFeatures(strings.NewReader(tt.doc))
+			if tt.wantErr {
+				if err == nil {
+					t.Error("got no error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(feats) != tt.want {
+				t.Errorf("got %d features, want %d", len(feats), tt.want)
+			}
+		})
+	}
+}
+
+func TestGeoJSONFeatureExtra(t *testing.T) {
+	const doc = `{"type":"Feature","id":7,"properties":{"name":"a"},"geometry":{"type":"Point","coordinates":[1,2]},"title":"wa","bbox":[1,2,1,2]}`
+
+	var f GeoJSONFeature
+	if err := json.Unmarshal([]byte(doc), &f); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]json.RawMessage{"bbox": json.RawMessage(`[1,2,1,2]`), "title": json.RawMessage(`"wa"`)}
+	if !reflect.DeepEqual(f.Extra, want) {
+		t.Fatalf("got extra members %s, want %s", f.Extra, want)
+	}
+
+	enc, err := json.Marshal(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(enc), `{"type":"Feature","id":7,"properties":{"name":"a"},"geometry":{"type":"Point","coordinates":[1,2]},"bbox":[1,2,1,2],"title":"wa"}`; got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+
+	// extra members can't stand in for the feature's own
+	f.Extra["type"] = json.RawMessage(`"Nope"`)
+	f.Extra["geometry"] = json.RawMessage(`null`)
+	f.Extra["properties"] = json.RawMessage(`{}`)
+	enc, err = json.Marshal(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var again GeoJSONFeature
+	if err := json.Unmarshal(enc, &again); err != nil {
+		t.Fatal(err)
+	}
+	if again.Type != "Feature" || again.Geometry.Type != "Point" || again.Properties["name"] != "a" {
+		t.Errorf("extra members overwrote the feature: %s", enc)
+	}
+	if !reflect.DeepEqual(again.Extra, want) {
+		t.Errorf("got extra members %s after encoding again, want %s", again.Extra, want)
+	}
+}
+
+func TestDecodeGeoJSONFeaturesGzip(t *testing.T) {
+	const doc = `{"type":"FeatureCollection","features":[{"type":"Feature","properties":{"name":"a"},"geometry":{"type":"Point","coordinates":[1,2]}}]}`
+
+	var gz bytes.Buffer
+	zw := gzip.NewWriter(&gz)
+	if _, err := zw.Write([]byte(doc)); err != nil {
+		t.Fatal(err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	plain, err := DecodeGeoJSONFeatures(strings.NewReader(doc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	gunzipped, err := DecodeGeoJSONFeatures(&gz)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(plain) != 1 || plain[0].Properties["name"] != "a" {
+		t.Fatalf("got features %v", plain)
+	}
+	if !reflect.DeepEqual(gunzipped, plain) {
+		t.Errorf("got %v from gzipped input, want %v", gunzipped, plain)
+	}
+
+	// a stream too short to hold the gzip magic is read as it is
+	if _, err := DecodeGeoJSONFeatures(strings.NewReader("{")); err == nil {
+		t.Error("got no error decoding a truncated document")
+	}
+}
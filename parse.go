@@ -0,0 +1,145 @@
+package geokit
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/golang/geo/r1"
+	"github.com/golang/geo/s1"
+	"github.com/golang/geo/s2"
+)
+
+// ParseBBox parses a "minLng,minLat,maxLng,maxLat" string into an s2.Rect.
+func ParseBBox(s string) (s2.Rect, error) {
+	vals, err := parseFloats(s, 4)
+	if err != nil {
+		return s2.EmptyRect(), fmt.Errorf("invalid bbox %q: %v", s, err)
+	}
+
+	minLng, minLat, maxLng, maxLat := vals[0], vals[1], vals[2], vals[3]
+	if minLat > maxLat {
+		return s2.EmptyRect(), fmt.Errorf("invalid bbox %q: min latitude greater than max latitude", s)
+	}
+
+	lo := s2.LatLngFromDegrees(minLat, minLng)
+	hi := s2.LatLngFromDegrees(maxLat, maxLng)
+
+	// a bbox whose min lng is greater than its max deliberately crosses
+	// the antimeridian. IntervalFromEndpoints keeps that, and moves a min
+	// lng of -180 to 180 as s1.Interval requires.
+	rect := s2.Rect{
+		Lat: r1.Interval{Lo: lo.Lat.Radians(), Hi: hi.Lat.Radians()},
+		Lng: s1.IntervalFromEndpoints(lo.Lng.Radians(), hi.Lng.Radians()),
+	}
+
+	if !rect.IsValid() {
+		return s2.EmptyRect(), fmt.Errorf("invalid bbox %q: coordinates out of range", s)
+	}
+
+	return rect, nil
+}
+
+// ParseCircle parses a "lat,lng,radiusKm" string into an s2.Cap, on a
+// sphere of earthRadiusKm such as EarthRadiusKm.
+func ParseCircle(s string, earthRadiusKm float64) (s2.Cap, error) {
+	vals, err := parseFloats(s, 3)
+	if err != nil {
+		return s2.EmptyCap(), fmt.Errorf("invalid circle %q: %v", s, err)
+	}
+
+	center := s2.LatLngFromDegrees(vals[0], vals[1])
+	if !center.IsValid() {
+		return s2.EmptyCap(), fmt.Errorf("invalid circle %q: center out of range", s)
+	}
+	if vals[2] < 0 {
+		return s2.EmptyCap(), fmt.Errorf("invalid circle %q: radius must not be negative", s)
+	}
+
+	return CapFromRadiusKm(center, vals[2], earthRadiusKm), nil
+}
+
+// ParseLatLng parses a "lat,lng" string into an s2.LatLng.
+func ParseLatLng(s string) (s2.LatLng, error) {
+	vals, err := parseFloats(s, 2)
+	if err != nil {
+		return s2.LatLng{}, fmt.Errorf("invalid point %q: %v", s, err)
+	}
+
+	ll := s2.LatLngFromDegrees(vals[0], vals[1])
+	if !ll.IsValid() {
+		return s2.LatLng{}, fmt.Errorf("invalid point %q: coordinates out of range", s)
+	}
+
+	return ll, nil
+}
+
+// ValidateLevels checks that minLevel and maxLevel are S2 levels, with
+// 0 <= minLevel <= maxLevel <= 30.
+func ValidateLevels(minLevel, maxLevel int) error {
+	if minLevel < 0 || maxLevel > 30 || minLevel > maxLevel {
+		return fmt.Errorf("levels must satisfy 0 <= min <= max <= 30, got min %d and max %d", minLevel, maxLevel)
+	}
+	return nil
+}
+
+// ParseLevelRange parses a "min,max" string of S2 levels, e.g. 4,12,
+// requiring 0 <= min <= max <= 30.
+func ParseLevelRange(s string) (minLevel, maxLevel int, err error) {
+	parts := strings.Split(s, ",")
+	if len(parts) != 2 {
+		return 0, 0, fmt.Errorf("invalid level range %q: expected min,max", s)
+	}
+
+	if minLevel, err = strconv.Atoi(strings.TrimSpace(parts[0])); err != nil {
+		return 0, 0, fmt.Errorf("invalid level range %q: %v", s, err)
+	}
+	if maxLevel, err = strconv.Atoi(strings.TrimSpace(parts[1])); err != nil {
+		return 0, 0, fmt.Errorf("invalid level range %q: %v", s, err)
+	}
+	if err := ValidateLevels(minLevel, maxLevel); err != nil {
+		return 0, 0, fmt.Errorf("invalid level range %q: %v", s, err)
+	}
+
+	return minLevel, maxLevel, nil
+}
+
+// ParseSize parses a "widthxheight" string, e.g. 256x256, into positive
+// dimensions.
+func ParseSize(s string) (width, height int, err error) {
+	parts := strings.Split(s, "x")
+	if len(parts) != 2 {
+		return 0, 0, fmt.Errorf("invalid size %q: expected widthxheight", s)
+	}
+
+	if width, err = strconv.Atoi(parts[0]); err != nil {
+		return 0, 0, fmt.Errorf("invalid size %q: %v", s, err)
+	}
+	if height, err = strconv.Atoi(parts[1]); err != nil {
+		return 0, 0, fmt.Errorf("invalid size %q: %v", s, err)
+	}
+	if width <= 0 || height <= 0 {
+		return 0, 0, fmt.Errorf("invalid size %q: dimensions must be positive", s)
+	}
+
+	return width, height, nil
+}
+
+// parseFloats parses exactly n comma-separated numbers from s
+func parseFloats(s string, n int) ([]float64, error) {
+	parts := strings.Split(s, ",")
+	if len(parts) != n {
+		return nil, fmt.Errorf("expected %d comma-separated values, got %d", n, len(parts))
+	}
+
+	vals := make([]float64, n)
+	for i, part := range parts {
+		val, err := strconv.ParseFloat(strings.TrimSpace(part), 64)
+		if err != nil {
+			return nil, err
+		}
+		vals[i] = val
+	}
+
+	return vals, nil
+}
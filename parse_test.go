@@ -0,0 +1,214 @@
+package geokit
+
+import (
+	"math"
+	"testing"
+
+	"github.com/golang/geo/s2"
+)
+
+func TestParseBBox(t *testing.T) {
+	for _, tt := range []struct {
+		name    string
+		s       string
+		inside  [][2]float64
+		outside [][2]float64
+		wantErr bool
+	}{
+		{
+			name:    "simple",
+			s:       "0,0,10,10",
+			inside:  [][2]float64{{5, 5}, {0, 0}, {10, 10}},
+			outside: [][2]float64{{-1, 5}, {11, 5}, {5, 11}},
+		},
+		{
+			name:    "from the antimeridian",
+			s:       "-180,0,10,10",
+			inside:  [][2]float64{{-179, 5}, {0, 5}, {180, 5}},
+			outside: [][2]float64{{11, 5}, {179, 11}},
+		},
+		{
+			name:    "to the antimeridian",
+			s:       "-10,0,180,10",
+			inside:  [][2]float64{{-9, 5}, {179, 5}},
+			outside: [][2]float64{{-11, 5}, {-179, 5}},
+		},
+		{
+			name:    "across the antimeridian",
+			s:       "170,0,-170,10",
+			inside:  [][2]float64{{175, 5}, {-175, 5}, {180, 5}},
+			outside: [][2]float64{{0, 5}, {169, 5}},
+		},
+		{name: "min lat above max lat", s: "0,10,10,0", wantErr: true},
+		{name: "lng out of range", s: "0,0,200,10", wantErr: true},
+		{name: "lat out of range", s: "0,0,10,100", wantErr: true},
+		{name: "too few values", s: "0,0,10", wantErr: true},
+		{name: "not a number", s: "0,0,ten,10", wantErr: true},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			rect, err := ParseBBox(tt.s)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("got %v, want an error", rect)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for _, pos := range tt.inside {
+				if !rect.ContainsLatLng(s2.LatLngFromDegrees(pos[1], pos[0])) {
+					t.Errorf("bbox does not contain %v", pos)
+				}
+			}
+			for _, pos := range tt.outside {
+				if rect.ContainsLatLng(s2.LatLngFromDegrees(pos[1], pos[0])) {
+					t.Errorf("bbox contains %v", pos)
+				}
+			}
+		})
+	}
+}
+
+func TestParseBBoxCovering(t *testing.T) {
+	rect, err := ParseBBox("0,0,1,1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// every cell must touch the rectangle, and none reach far past it
+	window, _ := ParseBBox("-0.5,-0.5,1.5,1.5")
+	for _, cellID := range Cover(rect, 4, 12, 50, false) {
+		cellRect := s2.CellFromCellID(cellID).RectBound()
+		if !rect.Intersects(cellRect) {
+			t.Errorf("cell %s doesn't touch the bbox", cellID.ToToken())
+		}
+		if !window.Contains(cellRect) {
+			t.Errorf("cell %s reaches far outside the bbox", cellID.ToToken())
+		}
+	}
+}
+
+func TestParseCircle(t *testing.T) {
+	for _, tt := range []struct {
+		name     string
+		s        string
+		radiusKm float64
+		wantErr  bool
+	}{
+		{name: "10km", s: "47.6,-122.3,10", radiusKm: 10},
+		{name: "zero radius", s: "0,0,0", radiusKm: 0},
+		{name: "negative radius", s: "0,0,-1", wantErr: true},
+		{name: "center out of range", s: "91,0,1", wantErr: true},
+		{name: "too many values", s: "0,0,1,1", wantErr: true},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := ParseCircle(tt.s, EarthRadiusKm)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("got %v, want an error", c)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := AngleToKm(c.Radius(), EarthRadiusKm); math.Abs(got-tt.radiusKm) > 1e-6 {
+				t.Errorf("got radius %v km, want %v", got, tt.radiusKm)
+			}
+		})
+	}
+}
+
+func TestParseLatLng(t *testing.T) {
+	for _, tt := range []struct {
+		s        string
+		lat, lng float64
+		wantErr  bool
+	}{
+		{s: "47.6,-122.3", lat: 47.6, lng: -122.3},
+		{s: " 1 , 2 ", lat: 1, lng: 2},
+		{s: "-91,0", wantErr: true},
+		{s: "0,181", wantErr: true},
+		{s: "0", wantErr: true},
+	} {
+		t.Run(tt.s, func(t *testing.T) {
+			ll, err := ParseLatLng(tt.s)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("got %v, want an error", ll)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if math.Abs(ll.Lat.Degrees()-tt.lat) > 1e-9 || math.Abs(ll.Lng.Degrees()-tt.lng) > 1e-9 {
+				t.Errorf("got %v, want %v,%v", ll, tt.lat, tt.lng)
+			}
+		})
+	}
+}
+
+func TestParseLevelRange(t *testing.T) {
+	for _, tt := range []struct {
+		s        string
+		min, max int
+		wantErr  bool
+	}{
+		{s: "4,12", min: 4, max: 12},
+		{s: "0,30", min: 0, max: 30},
+		{s: " 6 , 6 ", min: 6, max: 6},
+		{s: "12,4", wantErr: true},
+		{s: "4,31", wantErr: true},
+		{s: "-1,4", wantErr: true},
+		{s: "4", wantErr: true},
+		{s: "a,b", wantErr: true},
+	} {
+		t.Run(tt.s, func(t *testing.T) {
+			min, max, err := ParseLevelRange(tt.s)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("got %d,%d, want an error", min, max)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if min != tt.min || max != tt.max {
+				t.Errorf("got %d,%d, want %d,%d", min, max, tt.min, tt.max)
+			}
+		})
+	}
+}
+
+func TestParseSize(t *testing.T) {
+	for _, tt := range []struct {
+		s             string
+		width, height int
+		wantErr       bool
+	}{
+		{s: "256x128", width: 256, height: 128},
+		{s: "0x10", wantErr: true},
+		{s: "10", wantErr: true},
+		{s: "axb", wantErr: true},
+	} {
+		t.Run(tt.s, func(t *testing.T) {
+			width, height, err := ParseSize(tt.s)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("got %dx%d, want an error", width, height)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if width != tt.width || height != tt.height {
+				t.Errorf("got %dx%d, want %dx%d", width, height, tt.width, tt.height)
+			}
+		})
+	}
+}
@@ -0,0 +1,75 @@
+package geokit
+
+import "math"
+
+// RoundCoordinates rounds every position of f's geometry, and its center
+// property if it has one, to precision decimal places, in place. Six
+// places is within about 0.1 m anywhere on Earth, well inside any cell
+// outline, and makes for much smaller output than full float64 precision.
+func RoundCoordinates(f *GeoJSONFeature, precision int) error {
+	round := func(x, y float64) (float64, float64) {
+		return roundTo(x, precision), roundTo(y, precision)
+	}
+
+	if center, ok := f.Properties["center"].([2]float64); ok {
+		center[0], center[1] = round(center[0], center[1])
+		f.Properties["center"] = center
+	}
+
+	if f.Geometry.IsNull() {
+		return nil
+	}
+
+	// cell outlines are rounded as built, sparing the reshaping below
+	switch coords := f.Geometry.Coordinates.(type) {
+	case [][][2]float64:
+		roundRings(coords, round)
+		return nil
+	case [][][][2]float64:
+		for _, poly := range coords {
+			roundRings(poly, round)
+		}
+		return nil
+	}
+
+	coords, err := decodedCoordinates(f.Geometry.Coordinates)
+	if err != nil {
+		return err
+	}
+
+	coords, err = mapPositions(coords, round)
+	if err != nil {
+		return err
+	}
+	f.Geometry.Coordinates = coords
+	return nil
+}
+
+// RoundBBox rounds bbox, as [west, south, east, north] degrees, outward to
+// precision decimal places, so it still contains everything it did.
+func RoundBBox(bbox []float64, precision int) []float64 {
+	if len(bbox) != 4 {
+		return bbox
+	}
+
+	scale := math.Pow(10, float64(precision))
+	return []float64{
+		math.Floor(bbox[0]*scale) / scale,
+		math.Floor(bbox[1]*scale) / scale,
+		math.Ceil(bbox[2]*scale) / scale,
+		math.Ceil(bbox[3]*scale) / scale,
+	}
+}
+
+func roundRings(rings [][][2]float64, round func(x, y float64) (float64, float64)) {
+	for _, ring := range rings {
+		for i, pos := range ring {
+			ring[i][0], ring[i][1] = round(pos[0], pos[1])
+		}
+	}
+}
+
+func roundTo(v float64, precision int) float64 {
+	scale := math.Pow(10, float64(precision))
+	return math.Round(v*scale) / scale
+}
@@ -0,0 +1,102 @@
+package geokit
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/golang/geo/s2"
+)
+
+func TestRoundCoordinates(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		feat func() GeoJSONFeature
+		want interface{}
+	}{
+		{
+			name: "decoded polygon",
+			feat: func() GeoJSONFeature {
+				feats, err := DecodeGeoJSONFeatures(strings.NewReader(`{"type":"FeatureCollection","features":[{"type":"Feature","properties":{},"geometry":{"type":"Polygon","coordinates":[[[0.123456,1.987654],[1.5,0],[0,0.000049],[0.123456,1.987654]]]}}]}`))
+				if err != nil {
+					t.Fatal(err)
+				}
+				return feats[0]
+			},
+			want: []interface{}{[]interface{}{
+				[]interface{}{0.12, 1.99},
+				[]interface{}{1.5, 0.0},
+				[]interface{}{0.0, 0.0},
+				[]interface{}{0.12, 1.99},
+			}},
+		},
+		{
+			name: "point with altitude",
+			feat: func() GeoJSONFeature {
+				return GeoJSONFeature{Geometry: GeoJSONGeometry{Type: "Point", Coordinates: []interface{}{1.2345, -6.789, 100.555}}}
+			},
+			// only lng and lat are rounded
+			want: []interface{}{1.23, -6.79, 100.555},
+		},
+		{
+			name: "cell outline",
+			feat: func() GeoJSONFeature {
+				return GeoJSONFeature{Geometry: GeoJSONGeometry{Type: "Polygon", Coordinates: [][][2]float64{{{0.001, 0.009}, {1.004, 2.005}}}}}
+			},
+			want: [][][2]float64{{{0, 0.01}, {1, 2.01}}},
+		},
+		{
+			name: "merged cell outlines",
+			feat: func() GeoJSONFeature {
+				return GeoJSONFeature{Geometry: GeoJSONGeometry{Type: "MultiPolygon", Coordinates: [][][][2]float64{{{{0.111, 0.999}}}, {{{-0.111, -0.999}}}}}}
+			},
+			want: [][][][2]float64{{{{0.11, 1}}}, {{{-0.11, -1}}}},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			feat := tt.feat()
+			if err := RoundCoordinates(&feat, 2); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(feat.Geometry.Coordinates, tt.want) {
+				t.Errorf("got %v, want %v", feat.Geometry.Coordinates, tt.want)
+			}
+		})
+	}
+}
+
+func TestRoundCoordinatesCellFeature(t *testing.T) {
+	cellID := s2.CellIDFromLatLng(s2.LatLngFromDegrees(47.6, -122.3)).Parent(12)
+	feat := CellToGeoJSONFeature(cellID)
+	if err := RoundCoordinates(&feat, 3); err != nil {
+		t.Fatal(err)
+	}
+
+	center := feat.Properties["center"].([2]float64)
+	if center[0] != roundTo(center[0], 3) || center[1] != roundTo(center[1], 3) {
+		t.Errorf("center %v wasn't rounded", center)
+	}
+	for _, pos := range feat.Geometry.Coordinates.([][][2]float64)[0] {
+		if pos[0] != roundTo(pos[0], 3) || pos[1] != roundTo(pos[1], 3) {
+			t.Errorf("position %v wasn't rounded", pos)
+		}
+	}
+}
+
+func TestRoundBBox(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		bbox []float64
+		want []float64
+	}{
+		{"rounds outward", []float64{-0.123, 0.123, 0.123, 0.987}, []float64{-0.13, 0.12, 0.13, 0.99}},
+		{"already round", []float64{1, 2, 3, 4}, []float64{1, 2, 3, 4}},
+		{"no bbox", nil, nil},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := RoundBBox(tt.bbox, 2); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,43 @@
+package geokit
+
+import (
+	"image"
+	"image/color"
+
+	"github.com/golang/geo/s2"
+)
+
+// CellsToRaster renders cellIDs as a width by height mask over their
+// bounding box, see CellsBBox. Pixels whose center falls within a cell are
+// white, all others black. Rows run north to south and columns west to
+// east, as raster tools expect. An empty image is returned if there are no
+// cells.
+func CellsToRaster(cellIDs []s2.CellID, width, height int) *image.Gray {
+	img := image.NewGray(image.Rect(0, 0, width, height))
+
+	bbox := CellsBBox(cellIDs)
+	if bbox == nil {
+		return img
+	}
+	west, south, east, north := bbox[0], bbox[1], bbox[2], bbox[3]
+
+	// boxes straddling the antimeridian run east past 180
+	if east < west {
+		east += 360
+	}
+
+	cu := s2.CellUnion(append([]s2.CellID(nil), cellIDs...))
+	cu.Normalize()
+
+	for y := 0; y < height; y++ {
+		lat := north - (float64(y)+0.5)*(north-south)/float64(height)
+		for x := 0; x < width; x++ {
+			lng := west + (float64(x)+0.5)*(east-west)/float64(width)
+			if cu.ContainsCellID(s2.CellIDFromLatLng(s2.LatLngFromDegrees(lat, lng))) {
+				img.SetGray(x, y, color.Gray{Y: 255})
+			}
+		}
+	}
+
+	return img
+}
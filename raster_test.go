@@ -0,0 +1,47 @@
+package geokit
+
+import (
+	"testing"
+
+	"github.com/golang/geo/s2"
+)
+
+func TestCellsToRaster(t *testing.T) {
+	parent := s2.CellIDFromLatLng(s2.LatLngFromDegrees(47.6, -122.3)).Parent(10)
+	children := parent.Children()
+	antimeridian := s2.CellIDFromLatLng(s2.LatLngFromDegrees(0, 180)).Parent(6)
+
+	for _, tt := range []struct {
+		name    string
+		cellIDs []s2.CellID
+
+		// fraction of pixels that should be white
+		min, max float64
+	}{
+		{"one cell", []s2.CellID{parent}, 0.4, 1},
+		{"opposite corners", []s2.CellID{children[0], children[2]}, 0.2, 0.8},
+		{"across the antimeridian", antimeridian.AllNeighbors(6), 0.5, 1},
+		{"no cells", nil, 0, 0},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			img := CellsToRaster(tt.cellIDs, 64, 32)
+			if b := img.Bounds(); b.Dx() != 64 || b.Dy() != 32 {
+				t.Fatalf("got a %dx%d image, want 64x32", b.Dx(), b.Dy())
+			}
+
+			var white int
+			for _, v := range img.Pix {
+				switch v {
+				case 255:
+					white++
+				case 0:
+				default:
+					t.Fatalf("got gray pixel %d, want black or white", v)
+				}
+			}
+			if f := float64(white) / float64(len(img.Pix)); f < tt.min || f > tt.max {
+				t.Errorf("got %v of pixels white, want %v to %v", f, tt.min, tt.max)
+			}
+		})
+	}
+}
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/bcwaldon/geokit"
+)
+
+// geocodeFlags configure the Google Maps client, and are shared by every
+// subcommand that geocodes
+type geocodeFlags struct {
+	apiKey      string
+	credentials string
+	qps         int
+	region      string
+	language    string
+	retries     int
+	cache       string
+	timeout     time.Duration
+
+	// set by newGeocoder when --geocode-cache is
+	cachingGeocoder *geokit.CachingGeocoder
+}
+
+func (g *geocodeFlags) register(fs *flag.FlagSet) {
+	fs.StringVar(&g.apiKey, "google-maps-api-key", "", "API key for Google Maps API, defaults to the key in --credentials or $GOOGLE_MAPS_API_KEY")
+	fs.StringVar(&g.credentials, "credentials", "", "path to a JSON file holding the Google Maps API key as api_key")
+	fs.IntVar(&g.qps, "geocode-qps", 0, "if positive, max Google Maps API requests per second")
+	fs.StringVar(&g.region, "geocode-region", "", "region to bias geocoding toward, as a ccTLD code such as uk")
+	fs.StringVar(&g.language, "geocode-language", "", "language of geocoding results, such as de")
+	fs.IntVar(&g.retries, "geocode-retries", 2, "times to retry Google Maps API requests that fail from rate limiting or server errors")
+	fs.StringVar(&g.cache, "geocode-cache", "", "path to JSON file caching geocoding results across runs")
+	fs.DurationVar(&g.timeout, "geocode-timeout", 10*time.Second, "max time to wait on each Google Maps API request")
+}
+
+func (g *geocodeFlags) validate() error {
+	if g.retries < 0 {
+		return inputErrorf("--geocode-retries must not be negative, got %d", g.retries)
+	}
+	return nil
+}
+
+// newGeocoder builds the Google Maps client the flags describe. The
+// MapsGeocoder is returned for reverse geocoding, alongside the Geocoder
+// wrapping it with retries and caching as asked.
+func (g *geocodeFlags) newGeocoder() (*geokit.MapsGeocoder, geokit.Geocoder, error) {
+	apiKey := g.apiKey
+	if apiKey == "" && g.credentials != "" {
+		var err error
+		if apiKey, err = geokit.ReadGoogleMapsCredentials(g.credentials); err != nil {
+			return nil, nil, inputErrorf("failed reading --credentials: %v", err)
+		}
+	}
+	apiKey = geokit.ResolveGoogleMapsAPIKey(apiKey)
+	if apiKey == "" {
+		return nil, nil, inputErrorf("must set --google-maps-api-key, --credentials or $GOOGLE_MAPS_API_KEY")
+	}
+
+	mapsGeocoder, err := geokit.NewMapsGeocoder(apiKey, g.qps)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed creating Google Maps client: %v", err)
+	}
+	mapsGeocoder.Region = g.region
+	mapsGeocoder.Language = g.language
+
+	// each attempt gets the whole --geocode-timeout, rather than retries
+	// sharing one
+	var geocoder geokit.Geocoder = timeoutGeocoder{geocoder: mapsGeocoder, timeout: g.timeout}
+	if g.retries > 0 {
+		geocoder = geokit.NewRetryingGeocoder(geocoder, g.retries, 500*time.Millisecond)
+	}
+
+	if g.cache != "" {
+		g.cachingGeocoder, err = geokit.NewCachingGeocoder(geocoder, g.cache)
+		if err != nil {
+			return nil, nil, err
+		}
+		geocoder = g.cachingGeocoder
+	}
+
+	return mapsGeocoder, geocoder, nil
+}
+
+// flush writes lookups made since the last flush to --geocode-cache, if
+// set. Call it once a batch of lookups is done.
+func (g *geocodeFlags) flush() error {
+	if g.cachingGeocoder == nil {
+		return nil
+	}
+	return g.cachingGeocoder.Flush()
+}
+
+// timeoutGeocoder gives each lookup by geocoder at most timeout
+type timeoutGeocoder struct {
+	geocoder geokit.Geocoder
+	timeout  time.Duration
+}
+
+func (t timeoutGeocoder) Geocode(ctx context.Context, addr string) ([]geokit.GeoJSONFeature, error) {
+	ctx, cancel := context.WithTimeout(ctx, t.timeout)
+	defer cancel()
+	return t.geocoder.Geocode(ctx, addr)
+}
+
+// runGeocode geocodes the addresses described by args, writing the
+// candidate points as a GeoJSON FeatureCollection
+func runGeocode(args []string) error {
+	fs := flag.NewFlagSet("s2-covering geocode", flag.ContinueOnError)
+
+	var geocoding geocodeFlags
+	geocoding.register(fs)
+
+	var flagAddress string
+	fs.StringVar(&flagAddress, "address", "", "address to geocode")
+
+	var flagAddressesFile string
+	fs.StringVar(&flagAddressesFile, "addresses-file", "", "path to file containing one address per line to geocode")
+
+	var flagAllCandidates bool
+	fs.BoolVar(&flagAllCandidates, "all-candidates", false, "if true, emit every Geocoding API candidate rather than only the best")
+
+	var flagOutput string
+	fs.StringVar(&flagOutput, "output", "", "path to write output to, defaults to stdout")
+
+	var flagPretty bool
+	fs.BoolVar(&flagPretty, "pretty", false, "if true, indent output GeoJSON")
+
+	if help, err := parseFlags(fs, args); help || err != nil {
+		return err
+	}
+
+	if err := geocoding.validate(); err != nil {
+		return err
+	}
+
+	var addrs []string
+	switch {
+	case flagAddress != "" && flagAddressesFile != "":
+		return inputErrorf("must only provide one of --address or --addresses-file")
+	case flagAddress != "":
+		addrs = []string{flagAddress}
+	case flagAddressesFile != "":
+		f, err := os.Open(flagAddressesFile)
+		if err != nil {
+			return inputErrorf("failed reading addresses file: %v", err)
+		}
+		addrs, err = geokit.ReadAddresses(f)
+		f.Close()
+		if err != nil {
+			return inputErrorf("failed reading addresses file: %v", err)
+		}
+	default:
+		return inputErrorf("must provide --address or --addresses-file")
+	}
+
+	_, geocoder, err := geocoding.newGeocoder()
+	if err != nil {
+		return err
+	}
+	// keep the lookups that succeeded even if a later one fails
+	defer geocoding.flush()
+
+	fc := geokit.GeoJSONFeatureCollection{
+		Type:     "FeatureCollection",
+		Features: []geokit.GeoJSONFeature{},
+	}
+	for _, addr := range addrs {
+		candidates, err := geocoder.Geocode(context.Background(), addr)
+		if err != nil {
+			return fmt.Errorf("failed geocoding %q: %v", addr, err)
+		}
+		if len(candidates) == 0 {
+			return inputErrorf("no Geocoding API results for %q", addr)
+		}
+		if !flagAllCandidates {
+			candidates = candidates[:1]
+		}
+		fc.Features = append(fc.Features, candidates...)
+	}
+	if err := geocoding.flush(); err != nil {
+		return err
+	}
+
+	return writeOutput(flagOutput, func(w io.Writer) error {
+		return writeJSON(w, fc, flagPretty)
+	})
+}
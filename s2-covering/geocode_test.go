@@ -0,0 +1,133 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bcwaldon/geokit"
+)
+
+func TestRunGeocode(t *testing.T) {
+	dir := t.TempDir()
+
+	// lookups are answered from the cache, so the key is never used
+	cache := filepath.Join(dir, "cache.json")
+	const cached = `{"1 main st":[{"type":"Feature","properties":{"address":"1 Main St"},"geometry":{"type":"Point","coordinates":[-122.3,47.6]}},{"type":"Feature","properties":{"address":"1 Main St"},"geometry":{"type":"Point","coordinates":[-70,40]}}]}`
+	if err := os.WriteFile(cache, []byte(cached), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	addresses := filepath.Join(dir, "addresses.txt")
+	if err := os.WriteFile(addresses, []byte("1 Main St\n\n1  MAIN st\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv(geokit.GoogleMapsAPIKeyEnv, "unused")
+
+	for _, tt := range []struct {
+		name   string
+		args   []string
+		points int
+	}{
+		{"address", []string{"-address", "1 Main St"}, 1},
+		{"all candidates", []string{"-address", "1 Main St", "-all-candidates"}, 2},
+		{"addresses file", []string{"-addresses-file", addresses}, 2},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			out := filepath.Join(dir, strings.ReplaceAll(tt.name, " ", "_")+".json")
+			args := append([]string{"geocode", "-geocode-cache", cache, "-output", out}, tt.args...)
+			if err := run(args); err != nil {
+				t.Fatal(err)
+			}
+
+			f, err := os.Open(out)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer f.Close()
+			feats, err := geokit.DecodeGeoJSONFeatures(f)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(feats) != tt.points {
+				t.Errorf("got %d points, want %d", len(feats), tt.points)
+			}
+		})
+	}
+}
+
+func TestRunGeocodeErrors(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv(geokit.GoogleMapsAPIKeyEnv, "")
+
+	for _, tt := range []struct {
+		name string
+		args []string
+	}{
+		{"no address", nil},
+		{"address and file", []string{"-address", "x", "-addresses-file", "y"}},
+		{"missing addresses file", []string{"-addresses-file", filepath.Join(dir, "missing.txt")}},
+		{"negative retries", []string{"-address", "x", "-geocode-retries", "-1"}},
+		{"no API key", []string{"-address", "x"}},
+		{"missing credentials", []string{"-address", "x", "-credentials", filepath.Join(dir, "missing.json")}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			err := run(append([]string{"geocode"}, tt.args...))
+			if got := exitStatus(err); got != 2 {
+				t.Errorf("got exit status %d, want 2 (error: %v)", got, err)
+			}
+		})
+	}
+}
+
+func TestGeocodeFlagsRegionLanguage(t *testing.T) {
+	var g geocodeFlags
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	g.register(fs)
+	if err := fs.Parse([]string{"-google-maps-api-key", "unused", "-geocode-region", "uk", "-geocode-language", "de"}); err != nil {
+		t.Fatal(err)
+	}
+
+	mapsGeocoder, _, err := g.newGeocoder()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mapsGeocoder.Region != "uk" || mapsGeocoder.Language != "de" {
+		t.Errorf("got region %q and language %q, want uk and de", mapsGeocoder.Region, mapsGeocoder.Language)
+	}
+}
+
+// slowGeocoder never answers, recording how long each lookup was given
+type slowGeocoder struct {
+	budgets []time.Duration
+}
+
+func (s *slowGeocoder) Geocode(ctx context.Context, addr string) ([]geokit.GeoJSONFeature, error) {
+	deadline, _ := ctx.Deadline()
+	s.budgets = append(s.budgets, time.Until(deadline))
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func TestTimeoutGeocoder(t *testing.T) {
+	const timeout = 20 * time.Millisecond
+	slow := &slowGeocoder{}
+	g := geokit.NewRetryingGeocoder(timeoutGeocoder{geocoder: slow, timeout: timeout}, 2, time.Millisecond)
+
+	if _, err := g.Geocode(context.Background(), "1 Main St"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("got error %v, want the deadline", err)
+	}
+	if len(slow.budgets) != 3 {
+		t.Fatalf("got %d attempts, want 3", len(slow.budgets))
+	}
+	// retries don't share what's left of the first attempt's timeout
+	for i, budget := range slow.budgets {
+		if budget <= 0 || budget > timeout || budget < timeout/2 {
+			t.Errorf("attempt %d: got %v to answer, want about %v", i, budget, timeout)
+		}
+	}
+}
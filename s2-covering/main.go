@@ -1,264 +1,1348 @@
 package main
 
 import (
+	"bufio"
 	"context"
 	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
-	"io/ioutil"
+	"image/png"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"time"
 
+	"github.com/bcwaldon/geokit"
 	"github.com/golang/geo/s2"
-	"googlemaps.github.io/maps"
 )
 
-type GeoJSONFeatureCollection struct {
-	Type     string           `json:"type"`
-	Features []GeoJSONFeature `json:"features"`
-}
-
-type GeoJSONFeature struct {
-	Type       string                 `json:"type"`
-	Properties map[string]interface{} `json:"properties"`
-	Geometry   GeoJSONGeometry        `json:"geometry"`
-}
+func main() {
+	err := run(os.Args[1:])
 
-type GeoJSONGeometry struct {
-	Type        string      `json:"type"`
-	Coordinates interface{} `json:"coordinates"`
+	// the answer to --contains has already been printed, and the flag
+	// package reports bad flags itself, so only the status is left
+	var ue usageError
+	if err != nil && err != errNotContained && !errors.As(err, &ue) {
+		fmt.Fprintf(os.Stderr, "s2-covering: %v\n", err)
+	}
+	os.Exit(exitStatus(err))
 }
 
-func (f *GeoJSONFeature) TypedGeometry() (interface{}, error) {
-	var geo interface{}
-	switch f.Geometry.Type {
-	case "Point":
-		geo = new(GeoJSONPointGeometry)
-	case "Polygon":
-		geo = new(GeoJSONPolygonGeometry)
-	default:
-		return nil, fmt.Errorf("unsupported geometry %q", f.Geometry.Type)
+// exitStatus returns the status the process exits with after run returns
+// err: 0 on success, 2 for bad flags or input, 3 when something wasn't
+// contained by a covering and 1 for anything else
+func exitStatus(err error) int {
+	if err == nil {
+		return 0
 	}
-
-	enc, _ := json.Marshal(f.Geometry)
-	if err := json.Unmarshal(enc, geo); err != nil {
-		return nil, fmt.Errorf("failed decoding typed geometry: %v", err)
+	if err == errNotContained {
+		return 3
 	}
 
-	return geo, nil
+	var ie inputError
+	var ue usageError
+	if errors.As(err, &ie) || errors.As(err, &ue) {
+		return 2
+	}
+	return 1
 }
 
-type GeoJSONPolygonGeometry struct {
-	Type        string         `json:"type"`
-	Coordinates [][][2]float64 `json:"coordinates"`
+// inputError marks failures caused by bad flags or input data, as opposed to
+// failures of the covering itself or the services it depends on
+type inputError struct {
+	error
 }
 
-type GeoJSONPointGeometry struct {
-	Type        string     `json:"type"`
-	Coordinates [2]float64 `json:"coordinates"`
+func inputErrorf(format string, a ...interface{}) error {
+	return inputError{fmt.Errorf(format, a...)}
 }
 
-func DecodeGeoJSONFeatures(enc []byte) ([]GeoJSONFeature, error) {
-	var fc GeoJSONFeatureCollection
+// usageError is a flag parse error, which the flag package has already
+// written to stderr along with the usage
+type usageError struct {
+	error
+}
 
-	if err := json.Unmarshal(enc, &fc); err != nil {
-		return nil, fmt.Errorf("json decode failed: %v", err)
+// parseFlags parses args into fs, which must continue on error. Asking
+// for help isn't treated as a failure: help is set and no error returned,
+// so the caller can return nil.
+func parseFlags(fs *flag.FlagSet, args []string) (help bool, err error) {
+	err = fs.Parse(args)
+	if err == flag.ErrHelp {
+		return true, nil
 	}
-
-	if fc.Type != "FeatureCollection" {
-		return nil, fmt.Errorf("GeoJSON document type unsupported: %v", fc.Type)
+	if err != nil {
+		return false, usageError{err}
 	}
-
-	return fc.Features, nil
+	return false, nil
 }
 
-func GeoJSONPolygonToS2Polygon(poly *GeoJSONPolygonGeometry) *s2.Polygon {
-	var pts []s2.Point
-	for _, pt := range poly.Coordinates[0] {
-		pts = append(pts, s2.PointFromLatLng(s2.LatLngFromDegrees(pt[1], pt[0])))
+// errNotContained is returned when the --contains point falls outside the
+// covering, or one covering falls outside another, so that scripts can
+// branch on the exit status
+var errNotContained = errors.New("not contained by covering")
+
+// run dispatches args to the subcommand they name. Without one, args are
+// taken as flags to cover, as they were before there were subcommands.
+func run(args []string) error {
+	if len(args) > 0 {
+		switch args[0] {
+		case "cover":
+			return runCover(args[1:])
+		case "geocode":
+			return runGeocode(args[1:])
+		case "decode":
+			return runDecode(args[1:])
+		case "contains":
+			return runContains(args[1:])
+		case "help":
+			fmt.Fprint(os.Stderr, usage)
+			return nil
+		}
 	}
-	loop := s2.LoopFromPoints(pts)
-	return s2.PolygonFromLoops([]*s2.Loop{loop})
+	return runCover(args)
 }
 
-func Cover(r s2.Region, minLevel, maxLevel int, interior bool) []s2.CellID {
-	rc := &s2.RegionCoverer{MaxLevel: maxLevel, MinLevel: minLevel, MaxCells: 100000}
+// maxRasterSide is the widest and tallest --format raster image, keeping a
+// mistyped --raster-size from allocating gigabytes
+const maxRasterSide = 8192
 
-	var covering s2.CellUnion
-	if interior {
-		covering = rc.InteriorCovering(r)
-	} else {
-		covering = rc.Covering(r)
+const usage = `usage: s2-covering [cover] [flags]
+       s2-covering geocode [flags]
+       s2-covering decode [flags]
+       s2-covering contains -outer path -inner path
+
+cover    covers GeoJSON, addresses or shapes with S2 cells (the default)
+geocode  geocodes addresses to GeoJSON points
+decode   renders S2 cell tokens as GeoJSON, summaries or WKT
+contains checks that one file of S2 cell tokens lies within another
+
+Run s2-covering <command> -h for the flags of each command.
+`
+
+// runCover covers the input described by args, writing the result to
+// stdout or the --output file
+func runCover(args []string) error {
+	// bad flags exit with status 2, matching other input errors
+	fs := flag.NewFlagSet("s2-covering cover", flag.ContinueOnError)
+
+	var flagAddress string
+	fs.StringVar(&flagAddress, "address", "", "address that should be geocoded to a point")
+
+	var flagGeoJSON string
+	fs.StringVar(&flagGeoJSON, "geojson", "", "comma-separated paths to files containing GeoJSON FeatureCollections, or - for stdin")
+
+	var flagFormatIn string
+	fs.StringVar(&flagFormatIn, "format-in", "geojson", "format of --geojson input, one of geojson, topojson or wkb (hex-encoded, one geometry per line)")
+
+	var flagValidate bool
+	fs.BoolVar(&flagValidate, "validate", false, "if true, check the structure of GeoJSON input before decoding it, reporting the path of the first problem")
+
+	var flagInputCRS string
+	fs.StringVar(&flagInputCRS, "input-crs", "epsg:4326", "coordinate reference system of --geojson, --mask, --subtract and --serve input without a crs member, one of epsg:4326 or epsg:3857")
+
+	var flagBBox string
+	fs.StringVar(&flagBBox, "bbox", "", "rectangle to cover, as minLng,minLat,maxLng,maxLat")
+
+	var flagCircle string
+	fs.StringVar(&flagCircle, "circle", "", "circle to cover, as lat,lng,radiusKm")
+
+	var flagTokensFile string
+	fs.StringVar(&flagTokensFile, "tokens-file", "", "path to file containing one S2 cell token per line, emitted as is rather than covering anything")
+
+	var flagAllCandidates bool
+	fs.BoolVar(&flagAllCandidates, "all-candidates", false, "if true, cover every Geocoding API candidate for --address rather than only the best")
+
+	var flagAddressesFile string
+	fs.StringVar(&flagAddressesFile, "addresses-file", "", "path to file containing one address per line that should each be geocoded to a point")
+
+	var geocoding geocodeFlags
+	geocoding.register(fs)
+
+	var flagReverseGeocode bool
+	fs.BoolVar(&flagReverseGeocode, "reverse-geocode", false, "if true, annotate input Point features with their address")
+
+	var flagMerge bool
+	fs.BoolVar(&flagMerge, "merge", false, "if true, merge output into input GeoJSON, tagging each cell with the source_properties and source_geometry_type of the feature it came from")
+
+	var flagDedupeAcrossFeatures bool
+	fs.BoolVar(&flagDedupeAcrossFeatures, "dedupe-across-features", false, "if true with --merge, list the properties and geometry type of every input feature sharing a cell under source_properties and source_geometry_type")
+
+	var flagInterior bool
+	fs.BoolVar(&flagInterior, "interior", false, "if true, restrict covering to fully-contained cells")
+
+	var flagClassify bool
+	fs.BoolVar(&flagClassify, "classify", false, "if true, set each output cell's coverage property to interior if it lies fully inside the input, otherwise boundary")
+
+	var flagBoundaryOnly bool
+	fs.BoolVar(&flagBoundaryOnly, "boundary-only", false, "if true, drop from the covering the cells fully inside the input, leaving those along its edge")
+
+	var flagFloodFill string
+	fs.StringVar(&flagFloodFill, "flood-fill", "", "if set, cover with every cell at --max connected to the cell containing this lat,lng, which must be inside the input; fails if that takes more than --max-cells cells, and requires --max")
+
+	var flagAssumeLarge bool
+	fs.BoolVar(&flagAssumeLarge, "assume-large", false, "if true, take each polygon's exterior ring to enclose more than a hemisphere, as for oceans, whatever its winding")
+
+	var flagSimplifyTolerance float64
+	fs.Float64Var(&flagSimplifyTolerance, "simplify-tolerance", 0, "if positive, simplify polygon rings before covering, dropping vertices within this many meters of the outline kept")
+
+	var flagIgnoreHoles bool
+	fs.BoolVar(&flagIgnoreHoles, "ignore-holes", false, "if true, cover polygons as if they had no interior rings")
+
+	var flagComplement bool
+	fs.BoolVar(&flagComplement, "complement", false, "if true, cover the part of each feature's bounding rectangle outside the feature")
+
+	var flagBoundsOnly bool
+	fs.BoolVar(&flagBoundsOnly, "bounds-only", false, "if true, cover the bounding rectangle of each feature rather than its exact shape")
+
+	var flagMin, flagMax int
+	fs.IntVar(&flagMin, "min", 1, "min level of S2 cells desired")
+	fs.IntVar(&flagMax, "max", 30, "max level of S2 cells desired")
+
+	var flagLevel int
+	fs.IntVar(&flagLevel, "level", -1, "if set, cover with cells at exactly this level, overriding --min and --max")
+
+	var flagPointLevel int
+	fs.IntVar(&flagPointLevel, "point-level", -1, "if set, level of the cell covering each Point, in place of --max")
+
+	var flagLineLevels string
+	fs.StringVar(&flagLineLevels, "line-levels", "", "if set, min,max levels for LineString and MultiLineString features, in place of --min and --max")
+
+	var flagPolygonLevels string
+	fs.StringVar(&flagPolygonLevels, "polygon-levels", "", "if set, min,max levels for Polygon and MultiPolygon features, in place of --min and --max")
+
+	var flagLevelMod int
+	fs.IntVar(&flagLevelMod, "level-mod", 1, "only use cells at --min plus a multiple of this many levels, one of 1, 2 or 3")
+
+	var flagSnapLevel int
+	fs.IntVar(&flagSnapLevel, "snap-level", 0, "if positive, snap input vertices to the centers of cells at this level before covering")
+
+	var flagMaxCells int
+	fs.IntVar(&flagMaxCells, "max-cells", 100000, "max number of S2 cells desired per feature")
+
+	var flagCellAreaKm2 float64
+	fs.Float64Var(&flagCellAreaKm2, "cell-area-km2", 0, "if positive, give each polygon about one cell per this many km², up to --max-cells")
+
+	var flagTargetCells int
+	fs.IntVar(&flagTargetCells, "target-cells", 0, "if positive, use the finest max level between --min and --max whose covering has at most this many cells")
+
+	var flagMask string
+	fs.StringVar(&flagMask, "mask", "", "path to GeoJSON FeatureCollection outside of which cells are dropped from the covering")
+
+	var flagSubtract string
+	fs.StringVar(&flagSubtract, "subtract", "", "path to GeoJSON FeatureCollection whose interior is removed from the covering")
+
+	var flagFace int
+	fs.IntVar(&flagFace, "face", -1, "if set, keep only cells on this cube face, 0 through 5")
+
+	var flagCrop string
+	fs.StringVar(&flagCrop, "crop", "", "if set, keep only cells intersecting this minLng,minLat,maxLng,maxLat window")
+
+	var flagBufferRings int
+	fs.IntVar(&flagBufferRings, "buffer-rings", 0, "if positive, grow the covering by this many rings of neighboring cells")
+
+	var flagSimplify int
+	fs.IntVar(&flagSimplify, "simplify", 0, "if positive, coarsen output until it has at most this many cells")
+
+	var flagEarthRadiusKm float64
+	fs.Float64Var(&flagEarthRadiusKm, "earth-radius-km", geokit.EarthRadiusKm, "radius of the sphere used for areas and distances")
+
+	var flagRollupLevel int
+	fs.IntVar(&flagRollupLevel, "rollup-level", -1, "if set, replace output cells finer than this level with their ancestors at it, each with a childCount property")
+
+	var flagConcurrency int
+	fs.IntVar(&flagConcurrency, "concurrency", runtime.NumCPU(), "number of features to cover in parallel")
+
+	var flagContains string
+	fs.StringVar(&flagContains, "contains", "", "if set, print whether the covering contains this lat,lng point rather than the covering itself, exiting with status 3 if not")
+
+	var flagSort bool
+	fs.BoolVar(&flagSort, "sort", true, "if true, emit cells in ascending cell ID order")
+
+	var flagDistanceTo string
+	fs.StringVar(&flagDistanceTo, "distance-to", "", "if set, print the distance in km from this lat,lng point to the nearest cell rather than the covering itself")
+
+	var flagCountOnly bool
+	fs.BoolVar(&flagCountOnly, "count-only", false, "if true, print the number of cells in the covering rather than the covering itself")
+
+	var flagServe string
+	fs.StringVar(&flagServe, "serve", "", "if set, listen on this address and cover GeoJSON POSTed to it rather than covering a single input")
+
+	var flagOutput string
+	fs.StringVar(&flagOutput, "output", "", "path to file that output should be written to, defaults to stdout")
+
+	var flagSelfCheck bool
+	fs.BoolVar(&flagSelfCheck, "self-check", false, "if true, re-cover the outline of the covering and write the Jaccard similarity of the two to stderr")
+
+	var flagStats bool
+	fs.BoolVar(&flagStats, "stats", false, "if true, write covering metrics to stderr")
+
+	var flagFormat string
+	fs.StringVar(&flagFormat, "format", "geojson", "output format, one of geojson, ndjson, boundary, multipolygon (one feature holding every cell), summary, tokens, wkt or raster (a PNG mask over the covering's bbox)")
+
+	var flagRasterSize string
+	fs.StringVar(&flagRasterSize, "raster-size", "256x256", "dimensions of --format raster output, as widthxheight, each at most 8192")
+
+	var flagPretty bool
+	fs.BoolVar(&flagPretty, "pretty", false, "if true, indent output GeoJSON")
+
+	var flagPrecision int
+	fs.IntVar(&flagPrecision, "precision", -1, "if not negative, round output GeoJSON coordinates to this many decimal places, at most 15; 6 is within about 0.1 m")
+
+	var flagVerbose bool
+	fs.BoolVar(&flagVerbose, "verbose", false, "if true, log progress to stderr")
+
+	var flagQuiet bool
+	fs.BoolVar(&flagQuiet, "quiet", false, "if true, log nothing but errors to stderr")
+
+	if help, err := parseFlags(fs, args); help || err != nil {
+		return err
 	}
 
-	return []s2.CellID(covering)
-}
+	if flagVerbose && flagQuiet {
+		return inputErrorf("must only provide one of --verbose or --quiet")
+	}
+
+	// warnings are on unless asked to be quiet, progress only when asked
+	// to be verbose
+	warnLog := log.New(os.Stderr, "s2-covering: ", 0)
+	if flagQuiet {
+		warnLog.SetOutput(io.Discard)
+	}
+	verboseLog := log.New(io.Discard, "s2-covering: ", 0)
+	if flagVerbose {
+		verboseLog.SetOutput(os.Stderr)
+	}
 
-func CellsToGeoJSONFeatureCollection(cellIDs []s2.CellID) *GeoJSONFeatureCollection {
-	fc := GeoJSONFeatureCollection{
-		Type:     "FeatureCollection",
-		Features: make([]GeoJSONFeature, len(cellIDs)),
+	if flagEarthRadiusKm <= 0 {
+		return inputErrorf("--earth-radius-km must be positive, got %v", flagEarthRadiusKm)
 	}
 
-	for i, cellID := range cellIDs {
-		cellToken := cellID.ToToken()
-		cell := s2.CellFromCellID(s2.CellIDFromToken(cellToken))
+	if err := geocoding.validate(); err != nil {
+		return err
+	}
 
-		fc.Features[i].Type = "Feature"
+	if flagConcurrency <= 0 {
+		return inputErrorf("--concurrency must be positive, got %d", flagConcurrency)
+	}
 
-		fc.Features[i].Properties = map[string]interface{}{
-			"entity_id": cellToken,
-			"labels": map[string]string{
-				"s2CellToken": cellToken,
-				"s2Level":     fmt.Sprintf("%d", cell.Level()),
-			},
+	if flagLevel >= 0 {
+		if flagLevel > 30 {
+			return inputErrorf("--level must be at most 30, got %d", flagLevel)
 		}
+		flagMin, flagMax = flagLevel, flagLevel
+	}
+	if err := geokit.ValidateLevels(flagMin, flagMax); err != nil {
+		return inputErrorf("invalid --min and --max: %v", err)
+	}
 
-		fc.Features[i].Geometry.Type = "Polygon"
+	if flagRollupLevel < -1 || flagRollupLevel > 30 {
+		return inputErrorf("--rollup-level must be between 0 and 30, got %d", flagRollupLevel)
+	}
 
-		// have to reverse the order of lat/lng per GeoJSON
-		var coords [][2]float64
-		for _, point := range EdgesOfCell(cell) {
-			coords = append(coords, [2]float64{point[1], point[0]})
+	levels := make(typeLevels)
+	if flagPointLevel >= 0 {
+		if flagPointLevel > 30 {
+			return inputErrorf("--point-level must be at most 30, got %d", flagPointLevel)
+		}
+		levels["Point"] = [2]int{flagPointLevel, flagPointLevel}
+	}
+	for _, f := range []struct {
+		name  string
+		val   string
+		types []string
+	}{
+		{"line-levels", flagLineLevels, []string{"LineString", "MultiLineString"}},
+		{"polygon-levels", flagPolygonLevels, []string{"Polygon", "MultiPolygon"}},
+	} {
+		if f.val == "" {
+			continue
 		}
+		minLevel, maxLevel, err := geokit.ParseLevelRange(f.val)
+		if err != nil {
+			return inputErrorf("invalid --%s: %v", f.name, err)
+		}
+		for _, typ := range f.types {
+			levels[typ] = [2]int{minLevel, maxLevel}
+		}
+	}
 
-		fc.Features[i].Geometry.Coordinates = [][][2]float64{coords}
+	// float64 holds no more than about 15 significant digits, and
+	// beyond 308 places math.Pow overflows
+	if flagPrecision > 15 {
+		return inputErrorf("--precision must be at most 15, got %d", flagPrecision)
 	}
 
-	return &fc
-}
+	if flagFace < -1 || flagFace > 5 {
+		return inputErrorf("--face must be between 0 and 5, got %d", flagFace)
+	}
 
-func EdgesOfCell(c s2.Cell) [][2]float64 {
-	var edges [][2]float64
-	for i := 0; i < 4; i++ {
-		latLng := s2.LatLngFromPoint(c.Vertex(i))
-		edges = append(edges, [2]float64{latLng.Lat.Degrees(), latLng.Lng.Degrees()})
+	if flagLevelMod < 1 || flagLevelMod > 3 {
+		return inputErrorf("--level-mod must be 1, 2 or 3, got %d", flagLevelMod)
 	}
 
-	// need to close the loop
-	edges = append(edges, edges[0])
+	if flagSnapLevel > 30 {
+		return inputErrorf("--snap-level must be at most 30, got %d", flagSnapLevel)
+	}
 
-	return edges
-}
+	for _, f := range []struct {
+		name string
+		set  bool
+	}{
+		{"classify", flagClassify},
+		{"boundary-only", flagBoundaryOnly},
+		{"flood-fill", flagFloodFill != ""},
+	} {
+		if f.set && (flagInterior || flagComplement) {
+			return inputErrorf("--%s can't be combined with --interior or --complement", f.name)
+		}
+		if f.set && flagTokensFile != "" {
+			return inputErrorf("--%s needs shapes to cover, not --tokens-file", f.name)
+		}
+	}
 
-func Geocode(apiKey, addr string) (*GeoJSONGeometry, error) {
-	cl, err := maps.NewClient(maps.WithAPIKey(apiKey))
-	if err != nil {
-		return nil, err
+	if flagSelfCheck && flagComplement {
+		return inputErrorf("--self-check can't be combined with --complement")
+	}
+	if flagSelfCheck && flagTokensFile != "" {
+		return inputErrorf("--self-check needs shapes to cover, not --tokens-file")
 	}
 
-	req := maps.GeocodingRequest{
-		Address: addr,
+	if flagMaxCells <= 0 {
+		return inputErrorf("--max-cells must be positive, got %d", flagMaxCells)
+	}
+	if flagCellAreaKm2 < 0 {
+		return inputErrorf("--cell-area-km2 must not be negative, got %v", flagCellAreaKm2)
+	}
+	if flagSimplifyTolerance < 0 {
+		return inputErrorf("--simplify-tolerance must not be negative, got %v", flagSimplifyTolerance)
 	}
-	results, err := cl.Geocode(context.Background(), &req)
+
+	rasterWidth, rasterHeight, err := geokit.ParseSize(flagRasterSize)
 	if err != nil {
-		return nil, err
+		return inputErrorf("invalid --raster-size: %v", err)
+	}
+	if rasterWidth > maxRasterSide || rasterHeight > maxRasterSide {
+		return inputErrorf("--raster-size must be at most %dx%d, got %s", maxRasterSide, maxRasterSide, flagRasterSize)
 	}
 
-	if len(results) != 1 {
-		return nil, fmt.Errorf("expected one results from Geocoding API, received %d", len(results))
+	var floodFillSeed *s2.LatLng
+	if flagFloodFill != "" {
+		seed, err := geokit.ParseLatLng(flagFloodFill)
+		if err != nil {
+			return inputErrorf("invalid --flood-fill: %v", err)
+		}
+		floodFillSeed = &seed
+
+		// the default --max of 30 would fill with centimeter cells
+		maxSet := false
+		fs.Visit(func(f *flag.Flag) {
+			if f.Name == "max" {
+				maxSet = true
+			}
+		})
+		if !maxSet {
+			return inputErrorf("--flood-fill requires --max to be set")
+		}
+		if flagTargetCells > 0 {
+			return inputErrorf("--flood-fill can't be combined with --target-cells")
+		}
 	}
 
-	geo := GeoJSONGeometry{
-		Type: "Point",
-		Coordinates: [2]float64{
-			results[0].Geometry.Location.Lng,
-			results[0].Geometry.Location.Lat,
-		},
+	var cropRect s2.Rect
+	if flagCrop != "" {
+		if cropRect, err = geokit.ParseBBox(flagCrop); err != nil {
+			return inputErrorf("invalid --crop: %v", err)
+		}
 	}
 
-	return &geo, nil
-}
+	var queryCount int
+	for _, set := range []bool{flagCountOnly, flagContains != "", flagDistanceTo != ""} {
+		if set {
+			queryCount++
+		}
+	}
+	if queryCount > 1 {
+		return inputErrorf("must only provide one of --count-only, --contains or --distance-to")
+	}
 
-func main() {
-	var flagAddress string
-	flag.StringVar(&flagAddress, "address", "", "address that should be geocoded to a point")
+	var distanceToLatLng s2.LatLng
+	if flagDistanceTo != "" {
+		var err error
+		distanceToLatLng, err = geokit.ParseLatLng(flagDistanceTo)
+		if err != nil {
+			return inputError{err}
+		}
+	}
 
-	var flagGoogleMapsAPIKey string
-	flag.StringVar(&flagGoogleMapsAPIKey, "google-maps-api-key", "", "API key for Google Maps API")
+	var containsLatLng s2.LatLng
+	if flagContains != "" {
+		var err error
+		containsLatLng, err = geokit.ParseLatLng(flagContains)
+		if err != nil {
+			return inputError{err}
+		}
+	}
 
-	var flagGeoJSON string
-	flag.StringVar(&flagGeoJSON, "geojson", "", "path to file containing GeoJSON FeatureCollection")
+	var inputCount int
+	for _, in := range []string{flagAddress, flagAddressesFile, flagGeoJSON, flagBBox, flagCircle, flagTokensFile} {
+		if in != "" {
+			inputCount++
+		}
+	}
+	if inputCount > 1 {
+		return inputErrorf("must only provide one of --address, --addresses-file, --geojson, --bbox, --circle or --tokens-file")
+	}
 
-	var flagMerge bool
-	flag.BoolVar(&flagMerge, "merge", false, "if true, merge output into input GeoJSON")
+	coverer := geokit.Coverer{
+		MinLevel:    flagMin,
+		MaxLevel:    flagMax,
+		LevelMod:    flagLevelMod,
+		MaxCells:    flagMaxCells,
+		Interior:    flagInterior,
+		IgnoreHoles: flagIgnoreHoles,
+		AssumeLarge: flagAssumeLarge,
+		BoundsOnly:  flagBoundsOnly,
+		SnapLevel:   flagSnapLevel,
+		Complement:  flagComplement,
 
-	var flagInterior bool
-	flag.BoolVar(&flagInterior, "interior", false, "if true, restrict covering to fully-contained cells")
+		TargetCellAreaKm2:   flagCellAreaKm2,
+		SimplifyToleranceKm: flagSimplifyTolerance / 1000,
+		EarthRadiusKm:       flagEarthRadiusKm,
+		FloodFillSeed:       floodFillSeed,
+	}
 
-	var flagMin, flagMax int
-	flag.IntVar(&flagMin, "min", 1, "min level of S2 cells desired")
-	flag.IntVar(&flagMax, "max", 30, "max level of S2 cells desired")
+	if flagServe != "" {
+		if inputCount > 0 {
+			return inputErrorf("--serve reads input from requests, so must not be combined with --address, --addresses-file, --geojson, --bbox, --circle or --tokens-file")
+		}
+
+		// check the CRS now rather than failing every request
+		if err := geokit.ReprojectFeatures(nil, flagInputCRS); err != nil {
+			return inputErrorf("invalid --input-crs: %v", err)
+		}
+
+		handler := coverHandler{defaults: coverer, inputCRS: flagInputCRS, maxBodyBytes: maxRequestBytes, concurrency: flagConcurrency, logger: verboseLog}
+		warnLog.Printf("listening on %s", flagServe)
+		return http.ListenAndServe(flagServe, &handler)
+	}
+
+	var mapsGeocoder *geokit.MapsGeocoder
+	var geocoder geokit.Geocoder
+	if flagAddress != "" || flagAddressesFile != "" || flagReverseGeocode {
+		var err error
+		if mapsGeocoder, geocoder, err = geocoding.newGeocoder(); err != nil {
+			return err
+		}
+		// keep the lookups that succeeded even if a later one fails
+		defer geocoding.flush()
+	}
+
+	var inputFeatures []geokit.GeoJSONFeature
+
+	// set when covering a shape that isn't read from GeoJSON input
+	var inputRegion s2.Region
 
-	flag.Parse()
+	// set when reading cells rather than anything to cover
+	var inputCellIDs []s2.CellID
 
-	var inputFeatures []GeoJSONFeature
+	if flagTokensFile != "" {
+		f, err := os.Open(flagTokensFile)
+		if err != nil {
+			return inputErrorf("failed reading tokens file: %v", err)
+		}
+		cellIDs, invalid, err := geokit.ReadCellTokens(f)
+		f.Close()
+		if err != nil {
+			return inputErrorf("failed reading tokens file: %v", err)
+		}
 
-	if flagAddress != "" {
-		if flagGoogleMapsAPIKey == "" {
-			panic("must set --google-maps-api-key")
+		for _, token := range invalid {
+			warnLog.Printf("skipping invalid token %q", token)
 		}
+		inputCellIDs = cellIDs
 
-		geo, err := Geocode(flagGoogleMapsAPIKey, flagAddress)
+	} else if flagBBox != "" {
+		rect, err := geokit.ParseBBox(flagBBox)
 		if err != nil {
-			panic(fmt.Sprintf("failed geocoding: %v", err))
+			return inputError{err}
 		}
+		inputRegion = rect
 
-		inputFeatures = []GeoJSONFeature{
-			GeoJSONFeature{
-				Type:     "Feature",
-				Geometry: *geo,
-				Properties: map[string]interface{}{
-					"address": flagAddress,
-				},
-			},
+	} else if flagCircle != "" {
+		circle, err := geokit.ParseCircle(flagCircle, flagEarthRadiusKm)
+		if err != nil {
+			return inputError{err}
 		}
+		inputRegion = circle
 
-	} else if flagGeoJSON != "" {
-		raw, err := ioutil.ReadFile(flagGeoJSON)
+	} else if flagAddress != "" {
+		candidates, err := geocoder.Geocode(context.Background(), flagAddress)
 		if err != nil {
-			panic(fmt.Sprintf("failed reading input file: %v", err))
+			return fmt.Errorf("failed geocoding: %v", err)
+		}
+
+		if len(candidates) == 0 {
+			return inputErrorf("no Geocoding API results for %q", flagAddress)
 		}
 
-		inputFeatures, err = DecodeGeoJSONFeatures(raw)
+		if flagAllCandidates {
+			inputFeatures = candidates
+		} else {
+			inputFeatures = candidates[:1]
+		}
+		if err := geocoding.flush(); err != nil {
+			return err
+		}
+
+	} else if flagAddressesFile != "" {
+		f, err := os.Open(flagAddressesFile)
+		if err != nil {
+			return inputErrorf("failed reading addresses file: %v", err)
+		}
+		addrs, err := geokit.ReadAddresses(f)
+		f.Close()
 		if err != nil {
-			panic(fmt.Sprintf("failed decoding GeoJSON: %v", err))
+			return inputErrorf("failed reading addresses file: %v", err)
+		}
+
+		for _, addr := range addrs {
+			candidates, err := geocoder.Geocode(context.Background(), addr)
+			if err != nil {
+				return fmt.Errorf("failed geocoding %q: %v", addr, err)
+			}
+
+			if len(candidates) == 0 {
+				return inputErrorf("no Geocoding API results for %q", addr)
+			}
+
+			inputFeatures = append(inputFeatures, candidates[0])
+		}
+		if err := geocoding.flush(); err != nil {
+			return err
 		}
 
 	} else {
-		panic("must only provide one of --address or --geojson")
+		// read from stdin if asked to or if no input was provided at all
+		paths := []string{"-"}
+		if flagGeoJSON != "" {
+			paths = strings.Split(flagGeoJSON, ",")
+		}
+
+		for _, path := range paths {
+			feats, err := readFeatures(path, flagFormatIn, flagValidate)
+			if err != nil {
+				return err
+			}
+			inputFeatures = append(inputFeatures, feats...)
+		}
+
+		if err := geokit.ReprojectFeatures(inputFeatures, flagInputCRS); err != nil {
+			return inputErrorf("failed reprojecting input: %v", err)
+		}
+	}
+
+	for i, feat := range inputFeatures {
+		if feat.Geometry.IsNull() {
+			warnLog.Printf("skipping feature %d with null geometry", i)
+			continue
+		}
+		if !flagAssumeLarge && largeExterior(&feat) {
+			warnLog.Printf("feature %d: exterior ring is wound to enclose more than a hemisphere, but the smaller region it bounds is covered; set --assume-large to cover the larger", i)
+		}
+	}
+
+	if flagReverseGeocode {
+		for i, feat := range inputFeatures {
+			if feat.Geometry.IsNull() {
+				continue
+			}
+
+			geo, err := feat.TypedGeometry()
+			if err != nil {
+				return inputError{err}
+			}
+
+			pt, ok := geo.(*geokit.GeoJSONPointGeometry)
+			if !ok {
+				continue
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), geocoding.timeout)
+			addr, err := mapsGeocoder.ReverseGeocode(ctx, pt.Coordinates[1], pt.Coordinates[0])
+			cancel()
+			if err != nil {
+				return fmt.Errorf("failed reverse geocoding: %v", err)
+			}
+			if addr == "" {
+				warnLog.Printf("no address found for feature %d", i)
+				continue
+			}
+
+			if feat.Properties == nil {
+				inputFeatures[i].Properties = map[string]interface{}{}
+			}
+			inputFeatures[i].Properties["address"] = addr
+		}
+	}
+
+	if flagTokensFile != "" {
+		verboseLog.Printf("read %d cells", len(inputCellIDs))
+	} else if inputRegion == nil {
+		verboseLog.Printf("read %d features", len(inputFeatures))
 	}
 
 	var s2CellIDs []s2.CellID
+	var featureCellIDs [][]s2.CellID
+	chosenMaxLevel := -1
+
+	// cells fully inside the input, for --classify and --boundary-only
+	var interior s2.CellUnion
 
-	for _, feat := range inputFeatures {
-		geo, err := feat.TypedGeometry()
+	// how long covering took, for --stats
+	var coverDuration time.Duration
+	var featureDurations []time.Duration
+
+	if flagTokensFile != "" {
+		// cells given as input are emitted as they are
+		s2CellIDs = inputCellIDs
+	} else {
+		if flagTargetCells > 0 {
+			// SearchMaxLevel can't be interrupted, so hold on to the first
+			// failure and report it once the search is done
+			var searchErr error
+			chosenMaxLevel = geokit.SearchMaxLevel(flagMin, flagMax, flagTargetCells, func(level int) int {
+				if searchErr != nil {
+					return 0
+				}
+				c := coverer
+				c.MaxLevel = level
+				cellIDs, _, err := cover(c, levels, inputFeatures, inputRegion, flagConcurrency, nil, nil)
+				searchErr = err
+				verboseLog.Printf("max level %d produced %d cells", level, len(cellIDs))
+				return len(cellIDs)
+			})
+			if searchErr != nil {
+				return searchErr
+			}
+			verboseLog.Printf("chose max level %d", chosenMaxLevel)
+			coverer.MaxLevel = chosenMaxLevel
+		}
+
+		if flagStats {
+			featureDurations = make([]time.Duration, len(inputFeatures))
+		}
+
+		var err error
+		start := time.Now()
+		s2CellIDs, featureCellIDs, err = cover(coverer, levels, inputFeatures, inputRegion, flagConcurrency, verboseLog, featureDurations)
 		if err != nil {
-			panic(err)
-		}
-
-		switch geo.(type) {
-		case *GeoJSONPolygonGeometry:
-			poly := geo.(*GeoJSONPolygonGeometry)
-			s2Poly := GeoJSONPolygonToS2Polygon(poly)
-			s2CellIDs = append(s2CellIDs, Cover(s2.Region(s2Poly), flagMin, flagMax, flagInterior)...)
-		case *GeoJSONPointGeometry:
-			pt := geo.(*GeoJSONPointGeometry)
-			s2Point := s2.PointFromLatLng(s2.LatLngFromDegrees(pt.Coordinates[1], pt.Coordinates[0]))
-			s2CellIDs = append(s2CellIDs, Cover(s2.Region(s2Point), flagMin, flagMax, flagInterior)...)
-		default:
-			panic("unable to handle geometry")
+			return err
+		}
+		coverDuration = time.Since(start)
+		verboseLog.Printf("covering has %d cells", len(s2CellIDs))
+
+		// the coverer coarsens cells rather than exceed MaxCells, so a
+		// covering that reached it is likely coarser than asked for. Flood
+		// fills ignore MaxCells.
+		const maxCellsHint = "raise --max-cells, or lower --max to match the coarser cells"
+		if coverer.FloodFillSeed == nil {
+			if inputRegion != nil && len(s2CellIDs) >= coverer.MaxCells {
+				warnLog.Printf("covering reached --max-cells %d; %s", coverer.MaxCells, maxCellsHint)
+			}
+			for i, featCellIDs := range featureCellIDs {
+				if inputFeatures[i].Geometry.Type != "Point" && len(featCellIDs) >= coverer.MaxCells {
+					warnLog.Printf("feature %d: covering reached --max-cells %d; %s", i, coverer.MaxCells, maxCellsHint)
+				}
+			}
+		}
+
+		if flagSelfCheck {
+			// covering the outline of a covering with the same settings
+			// should give back much the same cells, though the outline
+			// is one shape, so --max-cells limits all of it at once
+			var outline []geokit.GeoJSONFeature
+			if boundary := geokit.CellsToBoundaryPolygon(s2CellIDs); boundary != nil {
+				outline = append(outline, geokit.GeoJSONFeature{Type: "Feature", Geometry: *boundary})
+			}
+			recovered, _, err := cover(coverer, nil, outline, nil, flagConcurrency, nil, nil)
+			if err != nil {
+				return fmt.Errorf("failed re-covering outline for --self-check: %v", err)
+			}
+			fmt.Fprintf(os.Stderr, "self-check: re-covered %d cells as %d, jaccard similarity %.4f\n", len(s2CellIDs), len(recovered), geokit.CoveringJaccard(s2CellIDs, recovered))
+		}
+
+		if flagClassify || flagBoundaryOnly {
+			interiorCoverer := coverer
+			interiorCoverer.Interior = true
+			interiorCoverer.FloodFillSeed = nil
+
+			// points and lines have no interior, though points are still
+			// covered by their cell; lines covered by their bounds do
+			var arealFeatures []geokit.GeoJSONFeature
+			for _, feat := range inputFeatures {
+				switch feat.Geometry.Type {
+				case "Point":
+					continue
+				case "LineString", "MultiLineString":
+					if !flagBoundsOnly {
+						continue
+					}
+				}
+				arealFeatures = append(arealFeatures, feat)
+			}
+
+			interiorCellIDs, _, err := cover(interiorCoverer, levels, arealFeatures, inputRegion, flagConcurrency, nil, nil)
+			if err != nil {
+				return err
+			}
+			interior = s2.CellUnion(interiorCellIDs)
+			interior.Normalize()
+			verboseLog.Printf("interior covering has %d cells", len(interior))
+		}
+
+		if flagBoundaryOnly {
+			s2CellIDs = geokit.NormalizeCellsLevelMod(geokit.SubtractCells(s2CellIDs, interior), coverer.MinLevel, coverer.LevelMod)
+			verboseLog.Printf("boundary covering has %d cells", len(s2CellIDs))
 		}
 	}
 
-	s2CellFC := CellsToGeoJSONFeatureCollection(s2CellIDs)
+	if flagMask != "" {
+		maskFeatures, err := readFeatures(flagMask, "geojson", flagValidate)
+		if err != nil {
+			return err
+		}
+		if err := geokit.ReprojectFeatures(maskFeatures, flagInputCRS); err != nil {
+			return inputErrorf("failed reprojecting --mask: %v", err)
+		}
 
+		// the mask is covered exactly as given, whatever was asked of the
+		// input
+		maskCoverer := coverer
+		maskCoverer.Interior = false
+		maskCoverer.IgnoreHoles = false
+		maskCoverer.BoundsOnly = false
+		maskCoverer.Complement = false
+		maskCoverer.FloodFillSeed = nil
+		maskCellIDs, _, err := cover(maskCoverer, nil, maskFeatures, nil, flagConcurrency, nil, nil)
+		if err != nil {
+			return inputErrorf("mask: %v", err)
+		}
+
+		s2CellIDs = geokit.NormalizeCellsLevelMod(geokit.IntersectCells(s2CellIDs, maskCellIDs), coverer.MinLevel, coverer.LevelMod)
+		verboseLog.Printf("masked covering has %d cells", len(s2CellIDs))
+	}
+
+	if flagSubtract != "" {
+		subtractFeatures, err := readFeatures(flagSubtract, "geojson", flagValidate)
+		if err != nil {
+			return err
+		}
+		if err := geokit.ReprojectFeatures(subtractFeatures, flagInputCRS); err != nil {
+			return inputErrorf("failed reprojecting --subtract: %v", err)
+		}
+
+		// only cells wholly inside the subtracted shape may be removed, so
+		// the result still covers everything outside it
+		subtractCoverer := coverer
+		subtractCoverer.Interior = true
+		subtractCoverer.IgnoreHoles = false
+		subtractCoverer.BoundsOnly = false
+		subtractCoverer.Complement = false
+		subtractCoverer.FloodFillSeed = nil
+		subtractCellIDs, _, err := cover(subtractCoverer, nil, subtractFeatures, nil, flagConcurrency, nil, nil)
+		if err != nil {
+			return inputErrorf("subtract: %v", err)
+		}
+
+		s2CellIDs = geokit.NormalizeCellsLevelMod(geokit.SubtractCells(s2CellIDs, subtractCellIDs), coverer.MinLevel, coverer.LevelMod)
+		verboseLog.Printf("covering has %d cells after subtracting", len(s2CellIDs))
+	}
+
+	if flagFace >= 0 {
+		// no cell spans two faces, so this is a plain filter
+		faceCellIDs := geokit.IntersectCells(s2CellIDs, []s2.CellID{s2.CellIDFromFace(flagFace)})
+		s2CellIDs = geokit.NormalizeCellsLevelMod(faceCellIDs, coverer.MinLevel, coverer.LevelMod)
+		verboseLog.Printf("covering has %d cells on face %d", len(s2CellIDs), flagFace)
+	}
+
+	if flagCrop != "" {
+		s2CellIDs = geokit.CropCells(s2CellIDs, cropRect)
+		verboseLog.Printf("cropped covering has %d cells", len(s2CellIDs))
+	}
+
+	if flagBufferRings > 0 {
+		s2CellIDs = geokit.ExpandCovering(s2CellIDs, flagBufferRings)
+		verboseLog.Printf("buffered covering has %d cells", len(s2CellIDs))
+	}
+
+	if flagSimplify > 0 {
+		s2CellIDs = geokit.SimplifyCells(s2CellIDs, flagSimplify)
+		verboseLog.Printf("simplified covering has %d cells", len(s2CellIDs))
+	}
+
+	// how many cells of the covering each output cell stands for, with
+	// --rollup-level
+	var childCounts map[s2.CellID]int
+	if flagRollupLevel >= 0 {
+		parents, counts := geokit.RollupCells(s2CellIDs, flagRollupLevel)
+		childCounts = make(map[s2.CellID]int, len(parents))
+		for i, parent := range parents {
+			childCounts[parent] = counts[i]
+		}
+		s2CellIDs = parents
+		verboseLog.Printf("rolled up covering has %d cells", len(s2CellIDs))
+	}
+
+	if flagStats {
+		stats := geokit.ComputeCoveringStats(s2CellIDs, flagEarthRadiusKm)
+		stats.ChosenMaxLevel = chosenMaxLevel
+		stats.CoverDuration = coverDuration
+		stats.FeatureCoverDurations = featureDurations
+
+		// the total is the ratio of all covered area to all polygon area,
+		// measured against the polygons as the coverer built them
+		var coveredArea, polyArea float64
+		for i := range inputFeatures {
+			regions, err := coverer.FeatureRegions(&inputFeatures[i])
+			if err != nil {
+				continue
+			}
+			var featArea float64
+			for _, r := range regions {
+				if poly, ok := r.(*s2.Polygon); ok {
+					featArea += poly.Area()
+				}
+			}
+			if featArea == 0 {
+				continue
+			}
+			featCells := s2.CellUnion(featureCellIDs[i])
+			coveredArea += featCells.ApproxArea()
+			polyArea += featArea
+		}
+		if polyArea > 0 {
+			stats.Overshoot = coveredArea / polyArea
+		}
+
+		stats.Fprint(os.Stderr)
+	}
+
+	// every step so far leaves the cells normalized, and so sorted, but
+	// don't rely on that holding for every combination of flags
+	if flagSort {
+		sort.Slice(s2CellIDs, func(i, j int) bool { return s2CellIDs[i] < s2CellIDs[j] })
+	}
+
+	if flagCountOnly {
+		fmt.Println(len(s2CellIDs))
+		return nil
+	}
+
+	if flagDistanceTo != "" {
+		if len(s2CellIDs) == 0 {
+			return inputErrorf("--distance-to needs a non-empty covering")
+		}
+		fmt.Println(geokit.AngleToKm(geokit.NearestCellDistance(s2CellIDs, distanceToLatLng), flagEarthRadiusKm))
+		return nil
+	}
+
+	if flagContains != "" {
+		contained := geokit.CoveringContainsPoint(s2CellIDs, containsLatLng)
+		fmt.Println(contained)
+		if !contained {
+			return errNotContained
+		}
+		return nil
+	}
+
+	var sources [][]int
 	if flagMerge {
-		s2CellFC.Features = append(inputFeatures, s2CellFC.Features...)
+		sources = geokit.CellSources(s2CellIDs, featureCellIDs)
+
+		if flagPrecision >= 0 {
+			for i := range inputFeatures {
+				if err := geokit.RoundCoordinates(&inputFeatures[i], flagPrecision); err != nil {
+					return inputErrorf("feature %d: failed rounding coordinates: %v", i, err)
+				}
+			}
+		}
+	}
+
+	// bbox is the bounding box of the cells as output
+	bbox := geokit.CellsBBox(s2CellIDs)
+	if flagPrecision >= 0 {
+		bbox = geokit.RoundBBox(bbox, flagPrecision)
+	}
+
+	// roundOutput rounds the coordinates of f, an outline of output cells
+	roundOutput := func(f *geokit.GeoJSONFeature) {
+		if flagPrecision >= 0 {
+			// these are all built as concrete rings, which can't fail
+			geokit.RoundCoordinates(f, flagPrecision)
+		}
+	}
+
+	// cellFeature renders the j-th output cell, classifying it when asked
+	// and pointing it back at the input feature it came from when merging
+	cellFeature := func(j int) geokit.GeoJSONFeature {
+		feat := geokit.CellToGeoJSONFeature(s2CellIDs[j])
+		roundOutput(&feat)
+		if flagEarthRadiusKm != geokit.EarthRadiusKm {
+			feat.Properties["areaKm2"] = geokit.CellAreaKm2(s2CellIDs[j], flagEarthRadiusKm)
+		}
+		if childCounts != nil {
+			feat.Properties["childCount"] = childCounts[s2CellIDs[j]]
+		}
+		if flagClassify {
+			feat.Properties["coverage"] = "boundary"
+			if interior.ContainsCellID(s2CellIDs[j]) {
+				feat.Properties["coverage"] = "interior"
+			}
+		}
+		if !flagMerge || len(sources[j]) == 0 {
+			return feat
+		}
+
+		if flagDedupeAcrossFeatures {
+			var sourceProps []map[string]interface{}
+			var sourceTypes []string
+			for _, src := range sources[j] {
+				sourceProps = append(sourceProps, inputFeatures[src].Properties)
+				sourceTypes = append(sourceTypes, inputFeatures[src].Geometry.Type)
+			}
+			feat.Properties["source_properties"] = sourceProps
+			feat.Properties["source_geometry_type"] = sourceTypes
+		} else {
+			src := inputFeatures[sources[j][0]]
+			feat.Properties["source_properties"] = src.Properties
+			feat.Properties["source_geometry_type"] = src.Geometry.Type
+		}
+
+		return feat
 	}
 
-	enc, err := json.Marshal(s2CellFC)
+	return writeOutput(flagOutput, func(bw io.Writer) error {
+		switch flagFormat {
+		case "geojson":
+			var featureCount int
+			feature := cellFeature
+			if flagMerge {
+				// input features come first, followed by the cells
+				featureCount = len(inputFeatures)
+				feature = func(j int) geokit.GeoJSONFeature {
+					if j < len(inputFeatures) {
+						return inputFeatures[j]
+					}
+					return cellFeature(j - len(inputFeatures))
+				}
+			}
+			featureCount += len(s2CellIDs)
+
+			var err error
+			if flagPretty {
+				// indenting needs the whole document up front
+				fc := geokit.GeoJSONFeatureCollection{
+					Type:     "FeatureCollection",
+					BBox:     bbox,
+					Features: make([]geokit.GeoJSONFeature, featureCount),
+				}
+				for j := range fc.Features {
+					fc.Features[j] = feature(j)
+				}
+				err = writeJSON(bw, fc, true)
+			} else {
+				err = geokit.StreamFeatureCollection(bw, bbox, featureCount, feature)
+			}
+			if err != nil {
+				return fmt.Errorf("failed encoding output FeatureCollection: %v", err)
+			}
+		case "ndjson":
+			enc := json.NewEncoder(bw)
+			if flagMerge {
+				for _, feat := range inputFeatures {
+					if err := enc.Encode(feat); err != nil {
+						return fmt.Errorf("failed encoding output Feature: %v", err)
+					}
+				}
+			}
+			for j := range s2CellIDs {
+				if err := enc.Encode(cellFeature(j)); err != nil {
+					return fmt.Errorf("failed encoding output Feature: %v", err)
+				}
+			}
+		case "tokens":
+			for _, token := range geokit.CellsToTokens(s2CellIDs) {
+				fmt.Fprintln(bw, token)
+			}
+		case "summary":
+			if err := writeJSON(bw, geokit.CellsToSummaries(s2CellIDs), flagPretty); err != nil {
+				return fmt.Errorf("failed encoding output summary: %v", err)
+			}
+		case "boundary":
+			boundaryFC := geokit.GeoJSONFeatureCollection{
+				Type:     "FeatureCollection",
+				BBox:     bbox,
+				Features: []geokit.GeoJSONFeature{},
+			}
+			if boundary := geokit.CellsToBoundaryPolygon(s2CellIDs); boundary != nil {
+				feat := geokit.GeoJSONFeature{
+					Type:       "Feature",
+					Properties: map[string]interface{}{},
+					Geometry:   *boundary,
+				}
+				roundOutput(&feat)
+				boundaryFC.Features = append(boundaryFC.Features, feat)
+			}
+
+			if err := writeJSON(bw, boundaryFC, flagPretty); err != nil {
+				return fmt.Errorf("failed encoding output FeatureCollection: %v", err)
+			}
+		case "multipolygon":
+			multiFC := geokit.GeoJSONFeatureCollection{
+				Type:     "FeatureCollection",
+				BBox:     bbox,
+				Features: []geokit.GeoJSONFeature{},
+			}
+			if multi := geokit.CellsToMultiPolygon(s2CellIDs); multi != nil {
+				feat := geokit.GeoJSONFeature{
+					Type:       "Feature",
+					Properties: map[string]interface{}{},
+					Geometry:   *multi,
+				}
+				roundOutput(&feat)
+				multiFC.Features = append(multiFC.Features, feat)
+			}
+
+			if err := writeJSON(bw, multiFC, flagPretty); err != nil {
+				return fmt.Errorf("failed encoding output FeatureCollection: %v", err)
+			}
+		case "raster":
+			img := geokit.CellsToRaster(s2CellIDs, rasterWidth, rasterHeight)
+			if err := png.Encode(bw, img); err != nil {
+				return fmt.Errorf("failed encoding output raster: %v", err)
+			}
+		case "wkt":
+			for _, cellID := range s2CellIDs {
+				fmt.Fprintln(bw, geokit.CellToWKT(cellID))
+			}
+		default:
+			return inputErrorf("unsupported --format %q", flagFormat)
+		}
+		return nil
+	})
+}
+
+// largeExterior reports whether any polygon of f has an exterior ring
+// likely meant to enclose more than a hemisphere
+func largeExterior(f *geokit.GeoJSONFeature) bool {
+	geo, err := f.TypedGeometry()
 	if err != nil {
-		panic(fmt.Sprintf("failed encoding output FeatureCollection: %v", err))
+		return false
+	}
+
+	var polys [][][][2]float64
+	switch g := geo.(type) {
+	case *geokit.GeoJSONPolygonGeometry:
+		polys = [][][][2]float64{g.Coordinates}
+	case *geokit.GeoJSONMultiPolygonGeometry:
+		polys = g.Coordinates
+	}
+
+	for _, poly := range polys {
+		if len(poly) > 0 && geokit.LikelyLargeRing(poly[0]) {
+			return true
+		}
+	}
+	return false
+}
+
+// typeLevels holds the min and max levels for features of each geometry
+// type, where they differ from the Coverer's
+type typeLevels map[string][2]int
+
+// cover covers each of feats, or region if set, returning the normalized
+// union of the coverings along with each feature's own cells. Each feature
+// is covered between the levels its s2MinLevel and s2MaxLevel properties
+// give, else those levels sets for its geometry type, else the coverer's.
+// Features that can't be covered are reported as input errors. If logger is
+// not nil, the size of each feature's covering is logged to it. If durations
+// is not nil, it receives how long each feature took to cover.
+func cover(coverer geokit.Coverer, levels typeLevels, feats []geokit.GeoJSONFeature, region s2.Region, concurrency int, logger *log.Logger, durations []time.Duration) ([]s2.CellID, [][]s2.CellID, error) {
+	if region != nil {
+		if coverer.FloodFillSeed == nil {
+			return geokit.NormalizeCellsLevelMod(coverer.CoverRegion(region), coverer.MinLevel, coverer.LevelMod), nil, nil
+		}
+
+		cellIDs, err := coverer.FloodFill(region)
+		if err == geokit.ErrFloodFillSeedOutside {
+			return nil, nil, inputErrorf("--flood-fill seed is not inside the input")
+		}
+		if err != nil {
+			return nil, nil, inputErrorf("%v; lower --max or raise --max-cells", err)
+		}
+		return geokit.NormalizeCellsLevelMod(cellIDs, coverer.MinLevel, coverer.LevelMod), nil, nil
+	}
+
+	featureCellIDs := make([][]s2.CellID, len(feats))
+	featureCoverers := make([]geokit.Coverer, len(feats))
+	normalizeMin := coverer.MinLevel
+
+	for i, feat := range feats {
+		minLevel, maxLevel := coverer.MinLevel, coverer.MaxLevel
+		if typeRange, ok := levels[feat.Geometry.Type]; ok {
+			minLevel, maxLevel = typeRange[0], typeRange[1]
+		}
+
+		minLevel, maxLevel, err := feat.CoveringLevels(minLevel, maxLevel)
+		if err != nil {
+			return nil, nil, inputErrorf("feature %d: %v", i, err)
+		}
+		if minLevel < normalizeMin {
+			normalizeMin = minLevel
+		}
+
+		featureCoverers[i] = coverer
+		featureCoverers[i].MinLevel = minLevel
+		featureCoverers[i].MaxLevel = maxLevel
+	}
+
+	// workers share nothing but the input and their own output slots, so
+	// the result doesn't depend on the order they finish in
+	featureErrs := make([]error, len(feats))
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < concurrency; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				start := time.Now()
+				featureCellIDs[i], featureErrs[i] = featureCoverers[i].CoverFeature(&feats[i])
+				if durations != nil {
+					durations[i] = time.Since(start)
+				}
+				if logger != nil && featureErrs[i] == nil {
+					logger.Printf("feature %d: %d cells", i, len(featureCellIDs[i]))
+				}
+			}
+		}()
+	}
+	for i := range feats {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	// a flood fill covers only the features holding the seed, of which
+	// there must be at least one
+	var cellIDs []s2.CellID
+	seeded := false
+	for i, err := range featureErrs {
+		switch {
+		case err == geokit.ErrFloodFillSeedOutside:
+			continue
+		case err != nil && coverer.FloodFillSeed != nil:
+			return nil, nil, inputErrorf("feature %d: %v; lower --max or raise --max-cells", i, err)
+		case err != nil:
+			return nil, nil, inputErrorf("feature %d: %v", i, err)
+		}
+		seeded = true
+		cellIDs = append(cellIDs, featureCellIDs[i]...)
+	}
+	if coverer.FloodFillSeed != nil && !seeded && len(feats) > 0 {
+		return nil, nil, inputErrorf("--flood-fill seed is not inside any input feature")
+	}
+
+	// overlapping features may produce the same cells
+	return geokit.NormalizeCellsLevelMod(cellIDs, normalizeMin, coverer.LevelMod), featureCellIDs, nil
+}
+
+// readFeatures decodes the features in the file at path, or stdin if path
+// is -, according to format. If validate is set, GeoJSON is checked for
+// structural problems first.
+func readFeatures(path, format string, validate bool) ([]geokit.GeoJSONFeature, error) {
+	in := os.Stdin
+	name := "stdin"
+	if path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, inputErrorf("failed reading input file: %v", err)
+		}
+		defer f.Close()
+		in = f
+		name = path
+	}
+
+	switch format {
+	case "geojson":
+		decode := geokit.DecodeGeoJSONFeatures
+		if validate {
+			decode = geokit.DecodeValidatedGeoJSONFeatures
+		}
+		feats, err := decode(in)
+		if err != nil {
+			return nil, inputErrorf("failed decoding GeoJSON from %s: %v", name, err)
+		}
+		return feats, nil
+	case "topojson":
+		feats, err := geokit.DecodeTopoJSONFeatures(in)
+		if err != nil {
+			return nil, inputErrorf("failed decoding TopoJSON from %s: %v", name, err)
+		}
+		return feats, nil
+	case "wkb":
+		feats, err := geokit.DecodeWKBHexFeatures(in)
+		if err != nil {
+			return nil, inputErrorf("failed decoding WKB from %s: %v", name, err)
+		}
+		return feats, nil
+	default:
+		return nil, inputErrorf("unsupported --format-in %q", format)
+	}
+}
+
+// writeOutput calls write with a buffered writer to the file at path, or
+// stdout if path is empty
+func writeOutput(path string, write func(w io.Writer) error) error {
+	var out io.Writer = os.Stdout
+	var f *os.File
+	if path != "" {
+		var err error
+		if f, err = os.Create(path); err != nil {
+			return fmt.Errorf("failed writing output file: %v", err)
+		}
+		out = f
+	}
+	bw := bufio.NewWriter(out)
+
+	err := write(bw)
+	if err == nil {
+		if err = bw.Flush(); err != nil {
+			err = fmt.Errorf("failed writing output: %v", err)
+		}
 	}
 
-	fmt.Printf(string(enc))
+	// a full disk may only surface when the file is closed
+	if f != nil {
+		if cerr := f.Close(); cerr != nil && err == nil {
+			err = fmt.Errorf("failed writing output file: %v", cerr)
+		}
+	}
+	return err
+}
+
+// writeJSON encodes v to w followed by a newline
+func writeJSON(w io.Writer, v interface{}, pretty bool) error {
+	enc := json.NewEncoder(w)
+	if pretty {
+		enc.SetIndent("", "  ")
+	}
+	return enc.Encode(v)
 }
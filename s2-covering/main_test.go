@@ -0,0 +1,541 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/bcwaldon/geokit"
+	"github.com/golang/geo/s2"
+)
+
+// readTestFeatures decodes the GeoJSON file at path, relative to the
+// repository root
+func readTestFeatures(tb testing.TB, path string) []geokit.GeoJSONFeature {
+	tb.Helper()
+	f, err := os.Open("../" + path)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	defer f.Close()
+
+	feats, err := geokit.DecodeGeoJSONFeatures(f)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	return feats
+}
+
+// runCapturingOutput runs args, returning what was written to stdout and
+// stderr
+func runCapturingOutput(t *testing.T, args []string) (string, string) {
+	t.Helper()
+	dir := t.TempDir()
+	capture := func(name string, f **os.File) func() string {
+		tmp, err := os.Create(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		saved := *f
+		*f = tmp
+		return func() string {
+			*f = saved
+			tmp.Close()
+			out, err := os.ReadFile(tmp.Name())
+			if err != nil {
+				t.Fatal(err)
+			}
+			return string(out)
+		}
+	}
+	stdout := capture("stdout", &os.Stdout)
+	stderr := capture("stderr", &os.Stderr)
+
+	err := run(args)
+	out, logged := stdout(), stderr()
+	if err != nil {
+		t.Fatalf("%v (stderr: %s)", err, logged)
+	}
+	return out, logged
+}
+
+// decodeOutput decodes the GeoJSON FeatureCollection in out
+func decodeOutput(t *testing.T, out string) []geokit.GeoJSONFeature {
+	t.Helper()
+	feats, err := geokit.DecodeGeoJSONFeatures(strings.NewReader(out))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return feats
+}
+
+func TestRunCoverFlags(t *testing.T) {
+	// far enough apart that no two features share a cell, and small
+	// enough that normalizing leaves their cells at the levels covered at
+	mixed := filepath.Join(t.TempDir(), "mixed.json")
+	const collection = `{"type": "FeatureCollection", "features": [
+		{"type": "Feature", "properties": {}, "geometry": {"type": "Point", "coordinates": [-30, 10]}},
+		{"type": "Feature", "properties": {}, "geometry": {"type": "LineString", "coordinates": [[0, 0], [0.1, 0.1]]}},
+		{"type": "Feature", "properties": {}, "geometry": {"type": "Polygon", "coordinates": [[[30, 30], [30.05, 30], [30.05, 30.05], [30, 30.05], [30, 30]]]}}
+	]}`
+	if err := os.WriteFile(mixed, []byte(collection), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	empty := filepath.Join(t.TempDir(), "empty.json")
+	if err := os.WriteFile(empty, []byte(`{"type": "FeatureCollection", "features": [{"type": "Feature", "properties": {}, "geometry": null}]}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range []struct {
+		name  string
+		args  []string
+		check func(t *testing.T, stdout, stderr string)
+	}{
+		{
+			name: "classify",
+			args: []string{"-bbox", "0,0,1,1", "-max", "10", "-classify"},
+			check: func(t *testing.T, stdout, stderr string) {
+				bbox := s2.RectFromLatLng(s2.LatLngFromDegrees(0, 0)).AddPoint(s2.LatLngFromDegrees(1, 1))
+				seen := make(map[interface{}]int)
+				for _, feat := range decodeOutput(t, stdout) {
+					coverage := feat.Properties["coverage"]
+					seen[coverage]++
+					cell := s2.CellFromCellID(s2.CellIDFromToken(feat.ID.(string)))
+					if coverage == "interior" && !bbox.Contains(cell.RectBound()) {
+						t.Errorf("interior cell %v isn't inside the bbox", feat.ID)
+					}
+				}
+				if len(seen) != 2 || seen["interior"] == 0 || seen["boundary"] == 0 {
+					t.Errorf("got coverage values %v, want interior and boundary cells only", seen)
+				}
+			},
+		},
+		{
+			name: "verbose",
+			args: []string{"-bbox", "0,0,1,1", "-max", "8", "-verbose"},
+			check: func(t *testing.T, stdout, stderr string) {
+				if !strings.Contains(stderr, "covering has") {
+					t.Errorf("got stderr %q, want progress logged", stderr)
+				}
+			},
+		},
+		{
+			name: "not verbose",
+			args: []string{"-bbox", "0,0,1,1", "-max", "8"},
+			check: func(t *testing.T, stdout, stderr string) {
+				if stderr != "" {
+					t.Errorf("got stderr %q, want nothing", stderr)
+				}
+			},
+		},
+		{
+			// the bbox needs more than one cell, which warns otherwise
+			name: "quiet",
+			args: []string{"-bbox", "0,0,1,1", "-max", "8", "-max-cells", "1", "-quiet"},
+			check: func(t *testing.T, stdout, stderr string) {
+				if stderr != "" {
+					t.Errorf("got stderr %q, want nothing", stderr)
+				}
+			},
+		},
+		{
+			name: "ndjson",
+			args: []string{"-bbox", "0,0,1,1", "-max", "8", "-format", "ndjson"},
+			check: func(t *testing.T, stdout, stderr string) {
+				tokens, _ := runCapturingOutput(t, []string{"-bbox", "0,0,1,1", "-max", "8", "-format", "tokens"})
+				want := strings.Fields(tokens)
+
+				lines := strings.Split(strings.TrimSuffix(stdout, "\n"), "\n")
+				if len(lines) != len(want) {
+					t.Fatalf("got %d lines, want one per cell, %d", len(lines), len(want))
+				}
+				for i, line := range lines {
+					var feat geokit.GeoJSONFeature
+					if err := json.Unmarshal([]byte(line), &feat); err != nil {
+						t.Fatalf("line %d: %v", i, err)
+					}
+					if feat.Type != "Feature" || feat.ID != want[i] {
+						t.Errorf("line %d: got a %s with id %v, want a Feature for %s", i, feat.Type, feat.ID, want[i])
+					}
+				}
+			},
+		},
+		{
+			name: "count only",
+			args: []string{"-bbox", "0,0,1,1", "-max", "8", "-count-only"},
+			check: func(t *testing.T, stdout, stderr string) {
+				tokens, _ := runCapturingOutput(t, []string{"-bbox", "0,0,1,1", "-max", "8", "-format", "tokens"})
+				if want := strconv.Itoa(len(strings.Fields(tokens))) + "\n"; stdout != want {
+					t.Errorf("got %q, want %q", stdout, want)
+				}
+			},
+		},
+		{
+			name: "max cells reached",
+			args: []string{"-bbox", "0,0,1,1", "-max", "8", "-max-cells", "4"},
+			check: func(t *testing.T, stdout, stderr string) {
+				if !strings.Contains(stderr, "covering reached --max-cells 4") {
+					t.Errorf("got stderr %q, want a warning", stderr)
+				}
+			},
+		},
+		{
+			name: "max cells reached by a feature",
+			args: []string{"-geojson", "../data/WA/counties.json", "-max", "8", "-max-cells", "4"},
+			check: func(t *testing.T, stdout, stderr string) {
+				if !strings.Contains(stderr, "feature 0: covering reached --max-cells 4") {
+					t.Errorf("got stderr %q, want a warning for feature 0", stderr)
+				}
+			},
+		},
+		{
+			name: "levels per geometry type",
+			args: []string{"-geojson", mixed, "-format", "tokens", "-point-level", "14", "-line-levels", "6,6", "-polygon-levels", "10,10"},
+			check: func(t *testing.T, stdout, stderr string) {
+				covered := make(map[int]bool)
+				for _, token := range strings.Fields(stdout) {
+					cellID := s2.CellIDFromToken(token)
+					var want int
+					switch lng := s2.LatLngFromPoint(cellID.Point()).Lng.Degrees(); {
+					case lng < -20:
+						want = 14
+					case lng < 20:
+						want = 6
+					default:
+						want = 10
+					}
+					if cellID.Level() != want {
+						t.Errorf("cell %s at %v is at level %d, want %d", token, s2.LatLngFromPoint(cellID.Point()), cellID.Level(), want)
+					}
+					covered[want] = true
+				}
+				if len(covered) != 3 {
+					t.Errorf("got cells for the features at levels %v, want all three covered", covered)
+				}
+			},
+		},
+		{
+			name: "sorted",
+			args: []string{"-geojson", "../data/WA/counties.json", "-max", "8", "-format", "tokens"},
+			check: func(t *testing.T, stdout, stderr string) {
+				tokens := strings.Fields(stdout)
+				if len(tokens) < 2 {
+					t.Fatalf("got %d cells, want several", len(tokens))
+				}
+				for i := 1; i < len(tokens); i++ {
+					if s2.CellIDFromToken(tokens[i-1]) >= s2.CellIDFromToken(tokens[i]) {
+						t.Errorf("cell %d, %s, doesn't sort after %s", i, tokens[i], tokens[i-1])
+					}
+				}
+
+				unsorted, _ := runCapturingOutput(t, []string{"-geojson", "../data/WA/counties.json", "-max", "8", "-format", "tokens", "-sort=false"})
+				got := strings.Fields(unsorted)
+				sort.Strings(got)
+				want := append([]string(nil), tokens...)
+				sort.Strings(want)
+				if !reflect.DeepEqual(got, want) {
+					t.Errorf("got %d cells with --sort=false, want the same %d cells", len(got), len(want))
+				}
+			},
+		},
+		{
+			name: "several input files",
+			args: []string{"-geojson", mixed + ",../data/WA/counties.json", "-max", "8", "-format", "tokens"},
+			check: func(t *testing.T, stdout, stderr string) {
+				var want []string
+				for _, path := range []string{mixed, "../data/WA/counties.json"} {
+					tokens, _ := runCapturingOutput(t, []string{"-geojson", path, "-max", "8", "-format", "tokens"})
+					want = append(want, strings.Fields(tokens)...)
+				}
+				sort.Strings(want)
+				got := strings.Fields(stdout)
+				sort.Strings(got)
+				if !reflect.DeepEqual(got, want) {
+					t.Errorf("got %d cells, want the %d cells of both files", len(got), len(want))
+				}
+			},
+		},
+		{
+			name: "nothing to cover",
+			args: []string{"-geojson", empty, "-quiet"},
+			check: func(t *testing.T, stdout, stderr string) {
+				if want := `{"type":"FeatureCollection","features":[]}` + "\n"; stdout != want {
+					t.Errorf("got %q, want %q", stdout, want)
+				}
+			},
+		},
+		{
+			name: "nothing to cover merged",
+			args: []string{"-geojson", empty, "-merge", "-quiet"},
+			check: func(t *testing.T, stdout, stderr string) {
+				if feats := decodeOutput(t, stdout); len(feats) != 1 || !feats[0].Geometry.IsNull() {
+					t.Errorf("got %v, want only the input feature", feats)
+				}
+			},
+		},
+		{
+			name: "single level",
+			args: []string{"-bbox", "0,0,1,1", "-min", "2", "-max", "12", "-level", "8", "-format", "tokens"},
+			check: func(t *testing.T, stdout, stderr string) {
+				tokens := strings.Fields(stdout)
+				if len(tokens) == 0 {
+					t.Fatal("got no cells")
+				}
+				for _, token := range tokens {
+					if level := s2.CellIDFromToken(token).Level(); level != 8 {
+						t.Errorf("cell %s is at level %d, want 8", token, level)
+					}
+				}
+			},
+		},
+		{
+			name: "self check",
+			args: []string{"-bbox", "0,0,1,1", "-max", "12", "-count-only", "-self-check"},
+			check: func(t *testing.T, stdout, stderr string) {
+				var covered, recovered int
+				var similarity float64
+				if _, err := fmt.Sscanf(stderr, "self-check: re-covered %d cells as %d, jaccard similarity %f", &covered, &recovered, &similarity); err != nil {
+					t.Fatalf("got stderr %q: %v", stderr, err)
+				}
+				if want := strings.TrimSpace(stdout); strconv.Itoa(covered) != want {
+					t.Errorf("got %d cells re-covered, want the covering's %s", covered, want)
+				}
+				// the outline of a covering covers much the same cells,
+				// along with slivers of the neighbors its edges touch
+				if similarity < 0.9 || similarity > 1 {
+					t.Errorf("got jaccard similarity %v, want nearly 1", similarity)
+				}
+			},
+		},
+		{
+			name: "earth radius",
+			args: []string{"-bbox", "0,0,1,1", "-max", "8", "-earth-radius-km", "1000"},
+			check: func(t *testing.T, stdout, stderr string) {
+				for _, feat := range decodeOutput(t, stdout) {
+					want := geokit.CellAreaKm2(s2.CellIDFromToken(feat.ID.(string)), 1000)
+					if got := feat.Properties["areaKm2"].(float64); math.Abs(got-want) > 1e-9*want {
+						t.Errorf("cell %v: got area %v km², want %v", feat.ID, got, want)
+					}
+				}
+			},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			stdout, stderr := runCapturingOutput(t, tt.args)
+			tt.check(t, stdout, stderr)
+		})
+	}
+}
+
+func TestRunCoverDeterministic(t *testing.T) {
+	// merging writes each input feature's properties, which are maps, and
+	// several workers finish in whatever order they like
+	args := []string{"-geojson", "../data/WA/counties.json", "-min", "4", "-max", "10", "-max-cells", "50", "-merge", "-concurrency", "4", "-quiet"}
+
+	var first []byte
+	for i := 0; i < 3; i++ {
+		path := filepath.Join(t.TempDir(), "out.json")
+		if err := run(append(args, "-output", path)); err != nil {
+			t.Fatal(err)
+		}
+		out, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if first == nil {
+			first = out
+		} else if !bytes.Equal(out, first) {
+			t.Fatalf("run %d wrote different output than the first", i)
+		}
+	}
+}
+
+func TestRunFloodFillMixedCollection(t *testing.T) {
+	dir := t.TempDir()
+	input := filepath.Join(dir, "in.json")
+	const collection = `{"type": "FeatureCollection", "features": [
+		{"type": "Feature", "geometry": {"type": "Polygon", "coordinates": [[[0, 0], [1, 0], [1, 1], [0, 1], [0, 0]]]}},
+		{"type": "Feature", "geometry": {"type": "Point", "coordinates": [50, 50]}},
+		{"type": "Feature", "geometry": null}
+	]}`
+	if err := os.WriteFile(input, []byte(collection), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	// the point and null geometry don't count as holding the seed
+	if err := run([]string{"-geojson", input, "-max", "8", "-flood-fill", "20,20", "-quiet"}); exitStatus(err) != 2 {
+		t.Errorf("got error %v for a seed outside the polygon, want an input error", err)
+	}
+
+	output := filepath.Join(dir, "out.txt")
+	if err := run([]string{"-geojson", input, "-max", "8", "-flood-fill", "0.5,0.5", "-format", "tokens", "-quiet", "-output", output}); err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.Open(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	cellIDs, _, err := geokit.ReadCellTokens(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !geokit.CoveringContainsPoint(cellIDs, s2.LatLngFromDegrees(0.5, 0.5)) {
+		t.Error("fill does not cover the seed")
+	}
+	if geokit.CoveringContainsPoint(cellIDs, s2.LatLngFromDegrees(50, 50)) {
+		t.Error("fill covers the point outside the polygon")
+	}
+}
+
+func TestRunMergeForeignMembers(t *testing.T) {
+	dir := t.TempDir()
+	input := filepath.Join(dir, "in.json")
+	const collection = `{"type": "FeatureCollection", "features": [
+		{"type": "Feature", "title": "square", "properties": {}, "geometry": {"type": "Polygon", "coordinates": [[[0, 0], [1, 0], [1, 1], [0, 1], [0, 0]]]}}
+	]}`
+	if err := os.WriteFile(input, []byte(collection), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	output := filepath.Join(dir, "out.json")
+	if err := run([]string{"-geojson", input, "-max", "8", "-merge", "-quiet", "-output", output}); err != nil {
+		t.Fatal(err)
+	}
+	out, err := os.Open(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer out.Close()
+	feats, err := geokit.DecodeGeoJSONFeatures(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := string(feats[0].Extra["title"]); got != `"square"` {
+		t.Errorf("got title %s on the merged input feature, want \"square\"", got)
+	}
+	if feats[0].Geometry.Type != "Polygon" {
+		t.Errorf("got a %s for the merged input feature, want a Polygon", feats[0].Geometry.Type)
+	}
+}
+
+func TestRunFlagErrors(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		args []string
+		want int
+	}{
+		{"cover help", []string{"-h"}, 0},
+		{"cover unknown flag", []string{"-nope"}, 2},
+		{"cover bad value", []string{"-max", "lots"}, 2},
+		{"geocode help", []string{"geocode", "-help"}, 0},
+		{"geocode unknown flag", []string{"geocode", "-nope"}, 2},
+		{"decode help", []string{"decode", "-h"}, 0},
+		{"decode unknown flag", []string{"decode", "-nope"}, 2},
+		{"contains help", []string{"contains", "-h"}, 0},
+		{"contains missing value", []string{"contains", "-outer"}, 2},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			stderr, err := os.Create(filepath.Join(t.TempDir(), "stderr"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer stderr.Close()
+			saved := os.Stderr
+			os.Stderr = stderr
+			err = run(tt.args)
+			os.Stderr = saved
+
+			if got := exitStatus(err); got != tt.want {
+				t.Errorf("got exit status %d, want %d (error: %v)", got, tt.want, err)
+			}
+			logged, err := os.ReadFile(stderr.Name())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Contains(logged, []byte("Usage of s2-covering")) {
+				t.Errorf("got stderr %q, want the usage", logged)
+			}
+		})
+	}
+}
+
+func TestExitStatus(t *testing.T) {
+	dir := t.TempDir()
+	writeTokens := func(name string, cellID s2.CellID) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(cellID.ToToken()+"\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	parent := s2.CellIDFromLatLng(s2.LatLngFromDegrees(47.6, -122.3)).Parent(10)
+	outer := writeTokens("outer.txt", parent)
+	inner := writeTokens("inner.txt", parent.ChildBegin())
+
+	for _, tt := range []struct {
+		name string
+		args []string
+		want int
+	}{
+		{"cover", []string{"-geojson", "../data/WA/counties.json", "-max", "8", "-quiet", "-output", filepath.Join(dir, "out.json")}, 0},
+		{"missing input", []string{"-geojson", filepath.Join(dir, "missing.json"), "-quiet"}, 2},
+		{"min above max", []string{"-geojson", "../data/WA/counties.json", "-min", "12", "-max", "4"}, 2},
+		{"max past 30", []string{"-geojson", "../data/WA/counties.json", "-max", "31"}, 2},
+		{"negative radius", []string{"-circle", "0,0,-1"}, 2},
+		{"rollup level", []string{"-bbox", "0,0,1,1", "-max", "8", "-rollup-level", "6", "-quiet", "-output", filepath.Join(dir, "rollup.json")}, 0},
+		{"negative rollup level", []string{"-bbox", "0,0,1,1", "-max", "8", "-rollup-level", "-2"}, 2},
+		{"rollup level past 30", []string{"-bbox", "0,0,1,1", "-max", "8", "-rollup-level", "31"}, 2},
+		{"precision 15", []string{"-bbox", "0,0,1,1", "-max", "8", "-precision", "15", "-quiet", "-output", filepath.Join(dir, "precision.json")}, 0},
+		{"precision past 15", []string{"-bbox", "0,0,1,1", "-max", "8", "-precision", "16"}, 2},
+		{"face", []string{"-bbox", "0,0,1,1", "-max", "8", "-face", "0", "-quiet", "-output", filepath.Join(dir, "face.json")}, 0},
+		{"negative face", []string{"-bbox", "0,0,1,1", "-max", "8", "-face", "-3"}, 2},
+		{"raster size", []string{"-bbox", "0,0,1,1", "-max", "8", "-format", "raster", "-raster-size", "64x32", "-quiet", "-output", filepath.Join(dir, "raster.png")}, 0},
+		{"raster size too large", []string{"-bbox", "0,0,1,1", "-max", "8", "-format", "raster", "-raster-size", "100000x100000"}, 2},
+		{"face past 5", []string{"-bbox", "0,0,1,1", "-max", "8", "-face", "9"}, 2},
+		{"serve with a bad CRS", []string{"-serve", "localhost:0", "-input-crs", "epsg:27700"}, 2},
+		{"flood fill without max", []string{"-bbox", "0,0,1,1", "-flood-fill", "0.5,0.5"}, 2},
+		{"unwritable output", []string{"-geojson", "../data/WA/counties.json", "-max", "8", "-quiet", "-output", filepath.Join(dir, "missing", "out.json")}, 1},
+		{"contained", []string{"contains", "-outer", outer, "-inner", inner, "-quiet"}, 0},
+		{"not contained", []string{"contains", "-outer", inner, "-inner", outer, "-quiet"}, 3},
+		{"contains without files", []string{"contains", "-outer", outer}, 2},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			err := run(tt.args)
+			if got := exitStatus(err); got != tt.want {
+				t.Errorf("got exit status %d, want %d (error: %v)", got, tt.want, err)
+			}
+		})
+	}
+}
+
+func BenchmarkCover(b *testing.B) {
+	feats := readTestFeatures(b, "data/WA/counties.json")
+	coverer := geokit.Coverer{MinLevel: 4, MaxLevel: 12, MaxCells: 200}
+
+	for _, bm := range []struct {
+		name        string
+		concurrency int
+	}{
+		{"serial", 1},
+		{"parallel", runtime.NumCPU()},
+	} {
+		b.Run(bm.name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				if _, _, err := cover(coverer, nil, feats, nil, bm.concurrency, nil, nil); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/bcwaldon/geokit"
+)
+
+// maxRequestBytes is the most GeoJSON a single request may POST
+const maxRequestBytes = 32 << 20
+
+// coverHandler covers GeoJSON FeatureCollections POSTed to it, responding
+// with the covering as a FeatureCollection. The min, max, max_cells and
+// interior query parameters override the corresponding fields of defaults.
+// Features without a crs member are taken to be in inputCRS, and bodies
+// larger than maxBodyBytes are refused.
+type coverHandler struct {
+	defaults     geokit.Coverer
+	inputCRS     string
+	maxBodyBytes int64
+	concurrency  int
+	logger       *log.Logger
+}
+
+func (h *coverHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "only POST is supported", http.StatusMethodNotAllowed)
+		return
+	}
+
+	coverer, err := h.coverer(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// MaxBytesReader stops reading at the limit, so a body that fills it
+	// exactly is one that went past it
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, h.maxBodyBytes))
+	if err != nil && int64(len(body)) == h.maxBodyBytes {
+		http.Error(w, fmt.Sprintf("request body must be at most %d bytes", h.maxBodyBytes), http.StatusRequestEntityTooLarge)
+		return
+	}
+	if err != nil {
+		http.Error(w, fmt.Sprintf("failed reading request: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	feats, err := geokit.DecodeGeoJSONFeatures(bytes.NewReader(body))
+	if err != nil {
+		http.Error(w, fmt.Sprintf("failed decoding GeoJSON: %v", err), http.StatusBadRequest)
+		return
+	}
+	if err := geokit.ReprojectFeatures(feats, h.inputCRS); err != nil {
+		http.Error(w, fmt.Sprintf("failed reprojecting GeoJSON: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	cellIDs, _, err := cover(coverer, nil, feats, nil, h.concurrency, nil, nil)
+	if err != nil {
+		status := http.StatusInternalServerError
+		var ie inputError
+		if errors.As(err, &ie) {
+			status = http.StatusBadRequest
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+	h.logger.Printf("covered %d features with %d cells", len(feats), len(cellIDs))
+
+	w.Header().Set("Content-Type", "application/geo+json")
+	if err := writeJSON(w, geokit.CellsToGeoJSONFeatureCollection(cellIDs), false); err != nil {
+		h.logger.Printf("failed writing response: %v", err)
+	}
+}
+
+// coverer applies the query parameters of r to the default Coverer
+func (h *coverHandler) coverer(r *http.Request) (geokit.Coverer, error) {
+	c := h.defaults
+	query := r.URL.Query()
+
+	for _, param := range []struct {
+		name string
+		dst  *int
+	}{
+		{"min", &c.MinLevel},
+		{"max", &c.MaxLevel},
+		{"max_cells", &c.MaxCells},
+	} {
+		val := query.Get(param.name)
+		if val == "" {
+			continue
+		}
+		n, err := strconv.Atoi(val)
+		if err != nil {
+			return c, fmt.Errorf("invalid %s %q: %v", param.name, val, err)
+		}
+		*param.dst = n
+	}
+
+	if val := query.Get("interior"); val != "" {
+		interior, err := strconv.ParseBool(val)
+		if err != nil {
+			return c, fmt.Errorf("invalid interior %q: %v", val, err)
+		}
+		c.Interior = interior
+	}
+
+	if err := geokit.ValidateLevels(c.MinLevel, c.MaxLevel); err != nil {
+		return c, err
+	}
+	if c.MaxCells <= 0 {
+		return c, fmt.Errorf("max_cells must be positive, got %d", c.MaxCells)
+	}
+
+	return c, nil
+}
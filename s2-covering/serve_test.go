@@ -0,0 +1,113 @@
+package main
+
+import (
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/bcwaldon/geokit"
+	"github.com/golang/geo/s2"
+)
+
+func TestCoverHandler(t *testing.T) {
+	const square = `{"type":"FeatureCollection","features":[{"type":"Feature","properties":{},"geometry":{"type":"Polygon","coordinates":[[[0,0],[1,0],[1,1],[0,1],[0,0]]]}}]}`
+
+	h := &coverHandler{
+		defaults:     geokit.Coverer{MinLevel: 4, MaxLevel: 10, MaxCells: 50},
+		inputCRS:     "epsg:4326",
+		maxBodyBytes: int64(len(square)) + 1,
+		concurrency:  2,
+		logger:       log.New(io.Discard, "", 0),
+	}
+	srv := httptest.NewServer(h)
+	defer srv.Close()
+
+	for _, tt := range []struct {
+		name   string
+		method string
+		query  string
+		body   string
+		status int
+		cells  int
+	}{
+		{name: "defaults", method: http.MethodPost, body: square, status: http.StatusOK, cells: 50},
+		{name: "query overrides", method: http.MethodPost, query: "?min=6&max=8&max_cells=5&interior=false", body: square, status: http.StatusOK, cells: 5},
+		{name: "interior", method: http.MethodPost, query: "?interior=true", body: square, status: http.StatusOK, cells: 50},
+		{name: "GET", method: http.MethodGet, status: http.StatusMethodNotAllowed},
+		{name: "bad level", method: http.MethodPost, query: "?max=31", body: square, status: http.StatusBadRequest},
+		{name: "min above max", method: http.MethodPost, query: "?min=12", body: square, status: http.StatusBadRequest},
+		{name: "not a number", method: http.MethodPost, query: "?max_cells=lots", body: square, status: http.StatusBadRequest},
+		{name: "no cells", method: http.MethodPost, query: "?max_cells=0", body: square, status: http.StatusBadRequest},
+		{name: "bad interior", method: http.MethodPost, query: "?interior=maybe", body: square, status: http.StatusBadRequest},
+		{name: "not GeoJSON", method: http.MethodPost, body: "{", status: http.StatusBadRequest},
+		{name: "too large", method: http.MethodPost, body: square + "  ", status: http.StatusRequestEntityTooLarge},
+		{name: "bad geometry", method: http.MethodPost, body: strings.Replace(square, "[1,1]", "[1,91]", 1), status: http.StatusBadRequest},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(tt.method, srv.URL+tt.query, strings.NewReader(tt.body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+
+			if resp.StatusCode != tt.status {
+				body, _ := io.ReadAll(resp.Body)
+				t.Fatalf("got status %d, want %d: %s", resp.StatusCode, tt.status, body)
+			}
+			if tt.status != http.StatusOK {
+				return
+			}
+
+			if ct := resp.Header.Get("Content-Type"); ct != "application/geo+json" {
+				t.Errorf("got Content-Type %q", ct)
+			}
+			feats, err := geokit.DecodeGeoJSONFeatures(resp.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(feats) == 0 || len(feats) > tt.cells {
+				t.Errorf("got %d cells, want 1 to %d", len(feats), tt.cells)
+			}
+		})
+	}
+}
+
+func TestCoverHandlerInputCRS(t *testing.T) {
+	// about a degree square north east of 0, 0, in Web Mercator meters
+	const square = `{"type":"FeatureCollection","features":[{"type":"Feature","properties":{},"geometry":{"type":"Polygon","coordinates":[[[0,0],[111319,0],[111319,111325],[0,111325],[0,0]]]}}]}`
+
+	h := &coverHandler{
+		defaults:     geokit.Coverer{MinLevel: 4, MaxLevel: 10, MaxCells: 50},
+		inputCRS:     "epsg:3857",
+		maxBodyBytes: maxRequestBytes,
+		concurrency:  2,
+		logger:       log.New(io.Discard, "", 0),
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/", strings.NewReader(square)))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("got status %d: %s", rec.Code, rec.Body)
+	}
+
+	feats, err := geokit.DecodeGeoJSONFeatures(rec.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var cellIDs []s2.CellID
+	for _, feat := range feats {
+		cellIDs = append(cellIDs, s2.CellIDFromToken(feat.ID.(string)))
+	}
+	if !geokit.CoveringContainsPoint(cellIDs, s2.LatLngFromDegrees(0.5, 0.5)) {
+		t.Error("covering misses the reprojected square")
+	}
+	if geokit.CoveringContainsPoint(cellIDs, s2.LatLngFromDegrees(10, 10)) {
+		t.Error("covering reaches well past the reprojected square")
+	}
+}
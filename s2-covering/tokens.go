@@ -0,0 +1,139 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+
+	"github.com/bcwaldon/geokit"
+	"github.com/golang/geo/s2"
+)
+
+// runDecode renders the cell tokens described by args, writing them in the
+// chosen --format
+func runDecode(args []string) error {
+	fs := flag.NewFlagSet("s2-covering decode", flag.ContinueOnError)
+
+	var flagTokensFile string
+	fs.StringVar(&flagTokensFile, "tokens-file", "-", "path to file containing one S2 cell token per line, or - for stdin")
+
+	var flagFormat string
+	fs.StringVar(&flagFormat, "format", "geojson", "output format, one of geojson, summary or wkt")
+
+	var flagOutput string
+	fs.StringVar(&flagOutput, "output", "", "path to write output to, defaults to stdout")
+
+	var flagPretty bool
+	fs.BoolVar(&flagPretty, "pretty", false, "if true, indent output JSON")
+
+	var flagQuiet bool
+	fs.BoolVar(&flagQuiet, "quiet", false, "if true, log nothing but errors to stderr")
+
+	if help, err := parseFlags(fs, args); help || err != nil {
+		return err
+	}
+
+	warnLog := log.New(os.Stderr, "s2-covering: ", 0)
+	if flagQuiet {
+		warnLog.SetOutput(io.Discard)
+	}
+
+	cellIDs, err := readTokens(flagTokensFile, warnLog)
+	if err != nil {
+		return err
+	}
+
+	return writeOutput(flagOutput, func(w io.Writer) error {
+		switch flagFormat {
+		case "geojson":
+			if err := writeJSON(w, geokit.CellsToGeoJSONFeatureCollection(cellIDs), flagPretty); err != nil {
+				return fmt.Errorf("failed encoding output FeatureCollection: %v", err)
+			}
+		case "summary":
+			if err := writeJSON(w, geokit.CellsToSummaries(cellIDs), flagPretty); err != nil {
+				return fmt.Errorf("failed encoding output summary: %v", err)
+			}
+		case "wkt":
+			for _, cellID := range cellIDs {
+				fmt.Fprintln(w, geokit.CellToWKT(cellID))
+			}
+		default:
+			return inputErrorf("unsupported --format %q", flagFormat)
+		}
+		return nil
+	})
+}
+
+// runContains reports whether the cells of the --inner token file lie
+// within those of the --outer one, printing true or false and failing with
+// errNotContained if not
+func runContains(args []string) error {
+	fs := flag.NewFlagSet("s2-covering contains", flag.ContinueOnError)
+
+	var flagOuter string
+	fs.StringVar(&flagOuter, "outer", "", "path to file containing one S2 cell token per line of the containing covering")
+
+	var flagInner string
+	fs.StringVar(&flagInner, "inner", "", "path to file containing one S2 cell token per line of the contained covering")
+
+	var flagQuiet bool
+	fs.BoolVar(&flagQuiet, "quiet", false, "if true, log nothing but errors to stderr")
+
+	if help, err := parseFlags(fs, args); help || err != nil {
+		return err
+	}
+
+	if flagOuter == "" || flagInner == "" {
+		return inputErrorf("must provide --outer and --inner")
+	}
+	if flagOuter == "-" && flagInner == "-" {
+		return inputErrorf("only one of --outer and --inner may be read from stdin")
+	}
+
+	warnLog := log.New(os.Stderr, "s2-covering: ", 0)
+	if flagQuiet {
+		warnLog.SetOutput(io.Discard)
+	}
+
+	outer, err := readTokens(flagOuter, warnLog)
+	if err != nil {
+		return err
+	}
+	inner, err := readTokens(flagInner, warnLog)
+	if err != nil {
+		return err
+	}
+
+	contained := geokit.CoveringContains(outer, inner)
+	fmt.Println(contained)
+	if !contained {
+		return errNotContained
+	}
+	return nil
+}
+
+// readTokens reads the cell tokens in the file at path, or stdin if path is
+// -, warning about any that are invalid
+func readTokens(path string, warnLog *log.Logger) ([]s2.CellID, error) {
+	in := os.Stdin
+	if path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, inputErrorf("failed reading tokens file: %v", err)
+		}
+		defer f.Close()
+		in = f
+	}
+
+	cellIDs, invalid, err := geokit.ReadCellTokens(in)
+	if err != nil {
+		return nil, inputErrorf("failed reading tokens file: %v", err)
+	}
+	for _, token := range invalid {
+		warnLog.Printf("skipping invalid token %q in %s", token, path)
+	}
+
+	return cellIDs, nil
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/bcwaldon/geokit"
+	"github.com/golang/geo/s2"
+)
+
+func TestRunDecode(t *testing.T) {
+	dir := t.TempDir()
+	parent := s2.CellIDFromLatLng(s2.LatLngFromDegrees(47.6, -122.3)).Parent(10)
+	cellIDs := []s2.CellID{parent, parent.Next()}
+
+	tokens := filepath.Join(dir, "tokens.txt")
+	content := strings.Join(geokit.CellsToTokens(cellIDs), "\n") + "\n\nnot-a-token\n"
+	if err := os.WriteFile(tokens, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range []struct {
+		format string
+		check  func(t *testing.T, out []byte)
+	}{
+		{"geojson", func(t *testing.T, out []byte) {
+			feats, err := geokit.DecodeGeoJSONFeatures(strings.NewReader(string(out)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(feats) != 2 || feats[0].ID != parent.ToToken() {
+				t.Errorf("got %d features, want one per valid token", len(feats))
+			}
+		}},
+		{"summary", func(t *testing.T, out []byte) {
+			var summaries []geokit.CellSummary
+			if err := json.Unmarshal(out, &summaries); err != nil {
+				t.Fatal(err)
+			}
+			want := geokit.CellsToSummaries(cellIDs)
+			if len(summaries) != 2 || summaries[0] != want[0] || summaries[1] != want[1] {
+				t.Errorf("got %v, want %v", summaries, want)
+			}
+		}},
+		{"wkt", func(t *testing.T, out []byte) {
+			lines := strings.Split(strings.TrimSpace(string(out)), "\n")
+			if len(lines) != 2 || lines[0] != geokit.CellToWKT(parent) {
+				t.Errorf("got %q, want a POLYGON per valid token", lines)
+			}
+		}},
+	} {
+		t.Run(tt.format, func(t *testing.T) {
+			path := filepath.Join(dir, tt.format+".out")
+			if err := run([]string{"decode", "-tokens-file", tokens, "-format", tt.format, "-output", path, "-quiet"}); err != nil {
+				t.Fatal(err)
+			}
+			out, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			tt.check(t, out)
+		})
+	}
+
+	err := run([]string{"decode", "-tokens-file", tokens, "-format", "kml", "-output", filepath.Join(dir, "kml.out"), "-quiet"})
+	if got := exitStatus(err); got != 2 {
+		t.Errorf("got exit status %d for an unknown format, want 2 (error: %v)", got, err)
+	}
+}
@@ -0,0 +1,164 @@
+package geokit
+
+import (
+	"sort"
+
+	"github.com/golang/geo/s1"
+	"github.com/golang/geo/s2"
+)
+
+// SimplifyCells coarsens cellIDs until there are at most maxCells of them.
+// Starting from the finest level present, cells are replaced by their
+// parent, preferring parents that absorb the most siblings. The result
+// covers everything cellIDs did, so area only grows.
+func SimplifyCells(cellIDs []s2.CellID, maxCells int) []s2.CellID {
+	cu := s2.CellUnion(append([]s2.CellID(nil), cellIDs...))
+	cu.Normalize()
+
+	for len(cu) > maxCells {
+		finest := 0
+		for _, cellID := range cu {
+			if cellID.Level() > finest {
+				finest = cellID.Level()
+			}
+		}
+		if finest == 0 {
+			break
+		}
+
+		children := make(map[s2.CellID]int)
+		for _, cellID := range cu {
+			if cellID.Level() == finest {
+				children[cellID.Parent(finest-1)]++
+			}
+		}
+
+		parents := make([]s2.CellID, 0, len(children))
+		for parent := range children {
+			parents = append(parents, parent)
+		}
+		sort.Slice(parents, func(i, j int) bool {
+			if children[parents[i]] != children[parents[j]] {
+				return children[parents[i]] > children[parents[j]]
+			}
+			return parents[i] < parents[j]
+		})
+
+		// replacing n children with their parent saves n-1 cells
+		excess := len(cu) - maxCells
+		for _, parent := range parents {
+			if excess <= 0 {
+				break
+			}
+			cu = append(cu, parent)
+			excess -= children[parent] - 1
+		}
+
+		cu.Normalize()
+	}
+
+	return []s2.CellID(cu)
+}
+
+// RollupCells maps each of cellIDs to its ancestor at level, returning the
+// distinct ancestors in order along with how many of cellIDs fell under
+// each. Duplicate cells count once, but cells are not normalized first, so
+// four sibling cells count as four. Cells already at or coarser than level
+// are passed through as they are, each counting once, rather than split
+// into their many descendants.
+func RollupCells(cellIDs []s2.CellID, level int) ([]s2.CellID, []int) {
+	sorted := append([]s2.CellID(nil), cellIDs...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	var parents []s2.CellID
+	var counts []int
+	index := make(map[s2.CellID]int)
+	for i, cellID := range sorted {
+		if i > 0 && cellID == sorted[i-1] {
+			continue
+		}
+
+		parent := cellID
+		if cellID.Level() > level {
+			parent = cellID.Parent(level)
+		}
+		// a coarse cell passed through can sort between descendants of
+		// the same parent, so they aren't always adjacent
+		if j, ok := index[parent]; ok {
+			counts[j]++
+			continue
+		}
+		index[parent] = len(parents)
+		parents = append(parents, parent)
+		counts = append(counts, 1)
+	}
+
+	return parents, counts
+}
+
+// SimplifyRing drops positions of ring, a closed ring of [lng, lat]
+// positions, that lie within tolerance of the great circle edges joining
+// those kept, using Douglas-Peucker. The result is closed too. Rings that
+// would be left with fewer than 3 distinct positions are returned as is.
+func SimplifyRing(ring [][2]float64, tolerance s1.Angle) [][2]float64 {
+	open := ring
+	if n := len(open); n > 1 && open[0] == open[n-1] {
+		open = open[:n-1]
+	}
+	if len(open) <= 3 {
+		return ring
+	}
+
+	// Douglas-Peucker needs distinct endpoints, so split the ring at the
+	// position farthest from the first, and close it back onto the first
+	pts := make([]s2.Point, len(open)+1)
+	for i, pos := range open {
+		pts[i] = s2.PointFromLatLng(s2.LatLngFromDegrees(pos[1], pos[0]))
+	}
+	pts[len(open)] = pts[0]
+
+	far := 1
+	for i := range open {
+		if pts[0].Distance(pts[i]) > pts[0].Distance(pts[far]) {
+			far = i
+		}
+	}
+
+	keep := make([]bool, len(pts))
+	keep[0], keep[far], keep[len(open)] = true, true, true
+	douglasPeucker(pts, 0, far, tolerance, keep)
+	douglasPeucker(pts, far, len(open), tolerance, keep)
+
+	var simplified [][2]float64
+	for i, pos := range open {
+		if keep[i] {
+			simplified = append(simplified, pos)
+		}
+	}
+	if len(simplified) < 3 {
+		return ring
+	}
+	return append(simplified, simplified[0])
+}
+
+// douglasPeucker marks in keep the points strictly between lo and hi that
+// are needed to stay within tolerance of pts[lo:hi+1]
+func douglasPeucker(pts []s2.Point, lo, hi int, tolerance s1.Angle, keep []bool) {
+	if hi-lo < 2 {
+		return
+	}
+
+	farthest, farthestDist := -1, tolerance
+	for i := lo + 1; i < hi; i++ {
+		if d := s2.DistanceFromSegment(pts[i], pts[lo], pts[hi]); d > farthestDist {
+			farthest, farthestDist = i, d
+		}
+	}
+	if farthest < 0 {
+		return
+	}
+
+	keep[farthest] = true
+	douglasPeucker(pts, lo, farthest, tolerance, keep)
+	douglasPeucker(pts, farthest, hi, tolerance, keep)
+}
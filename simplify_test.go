@@ -0,0 +1,110 @@
+package geokit
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/golang/geo/s1"
+	"github.com/golang/geo/s2"
+)
+
+func TestSimplifyCells(t *testing.T) {
+	feat := polygonFeature(circleRing(-122.3, 47.6, 0.25, 100))
+	c := Coverer{MinLevel: 4, MaxLevel: 14, MaxCells: 500}
+	cellIDs, err := c.CoverFeature(&feat)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, maxCells := range []int{len(cellIDs), 100, 20, 4, 1} {
+		simplified := SimplifyCells(cellIDs, maxCells)
+		if len(simplified) > maxCells {
+			t.Errorf("maxCells %d: got %d cells", maxCells, len(simplified))
+		}
+		if !CoveringContains(simplified, cellIDs) {
+			t.Errorf("maxCells %d: simplified covering lost area", maxCells)
+		}
+	}
+
+	// face cells can't be coarsened further
+	faces := []s2.CellID{s2.CellIDFromFace(0), s2.CellIDFromFace(2), s2.CellIDFromFace(4)}
+	if got := SimplifyCells(faces, 1); !reflect.DeepEqual(got, faces) {
+		t.Errorf("got %v, want the faces unchanged", got)
+	}
+}
+
+func TestRollupCells(t *testing.T) {
+	parent := s2.CellIDFromLatLng(s2.LatLngFromDegrees(47.6, -122.3)).Parent(8)
+	children := parent.Children()
+	other := parent.Next()
+
+	for _, tt := range []struct {
+		name    string
+		cellIDs []s2.CellID
+		level   int
+		want    []s2.CellID
+		counts  []int
+	}{
+		{"empty", nil, 8, nil, nil},
+		{"children of one parent", []s2.CellID{children[0], children[1], children[3]}, 8, []s2.CellID{parent}, []int{3}},
+		{"two parents", []s2.CellID{children[0], other.ChildBegin(), children[2]}, 8, []s2.CellID{parent, other}, []int{2, 1}},
+		{"grandchildren", []s2.CellID{children[0].ChildBegin(), children[1].ChildEnd().Prev()}, 8, []s2.CellID{parent}, []int{2}},
+		// each input cell counts, even where four siblings tile the parent
+		{"all four children", children[:], 8, []s2.CellID{parent}, []int{4}},
+		{"duplicates count once", []s2.CellID{children[0], children[0], children[1]}, 8, []s2.CellID{parent}, []int{2}},
+		{"unsorted", []s2.CellID{other.ChildBegin(), children[3], children[0]}, 8, []s2.CellID{parent, other}, []int{2, 1}},
+		{"coarser cells pass through", []s2.CellID{parent.Parent(6), other.Parent(6).Next()}, 8, []s2.CellID{parent.Parent(6), other.Parent(6).Next()}, []int{1, 1}},
+		{"at level", []s2.CellID{parent}, 8, []s2.CellID{parent}, []int{1}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			got, counts := RollupCells(tt.cellIDs, tt.level)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got cells %v, want %v", got, tt.want)
+			}
+			if !reflect.DeepEqual(counts, tt.counts) {
+				t.Errorf("got counts %v, want %v", counts, tt.counts)
+			}
+		})
+	}
+}
+
+func TestSimplifyRing(t *testing.T) {
+	square := squareRing(0, 0, 1)
+	tolerance := s1.Angle(0.01 * float64(s1.Degree))
+
+	for _, tt := range []struct {
+		name string
+		ring [][2]float64
+		want [][2]float64
+	}{
+		{"square", square, square},
+		{
+			name: "points along the sides",
+			ring: [][2]float64{{0, 0}, {0.5, 0}, {1, 0}, {1, 0.5}, {1, 1}, {0.5, 1}, {0, 1}, {0, 0.5}, {0, 0}},
+			want: square,
+		},
+		{
+			name: "wobble within tolerance",
+			ring: [][2]float64{{0, 0}, {0.5, 0.001}, {1, 0}, {1, 1}, {0, 1}, {0, 0}},
+			want: square,
+		},
+		{
+			name: "spike past tolerance",
+			ring: [][2]float64{{0, 0}, {0.5, -0.5}, {1, 0}, {1, 1}, {0, 1}, {0, 0}},
+			want: [][2]float64{{0, 0}, {0.5, -0.5}, {1, 0}, {1, 1}, {0, 1}, {0, 0}},
+		},
+		{"triangle", [][2]float64{{0, 0}, {1, 0}, {0, 1}, {0, 0}}, [][2]float64{{0, 0}, {1, 0}, {0, 1}, {0, 0}}},
+		{
+			// collapsing to a line would leave no ring
+			name: "sliver",
+			ring: [][2]float64{{0, 0}, {0.5, 0.0001}, {1, 0}, {0.5, -0.0001}, {0, 0}},
+			want: [][2]float64{{0, 0}, {0.5, 0.0001}, {1, 0}, {0.5, -0.0001}, {0, 0}},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := SimplifyRing(tt.ring, tolerance); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,125 @@
+package geokit
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"time"
+
+	"github.com/golang/geo/s2"
+)
+
+// EarthRadiusKm is the earth's mean radius, the usual radius of the sphere
+// on which areas and distances are measured.
+const EarthRadiusKm = 6371.0088
+
+// CellAreaKm2 returns the approximate area of cellID on a sphere of
+// earthRadiusKm, such as EarthRadiusKm.
+func CellAreaKm2(cellID s2.CellID, earthRadiusKm float64) float64 {
+	// ApproxArea is in steradians, i.e. on the unit sphere
+	return s2.CellFromCellID(cellID).ApproxArea() * earthRadiusKm * earthRadiusKm
+}
+
+// CoveringStats summarizes a set of cells.
+type CoveringStats struct {
+	CellCount   int
+	LevelCounts map[int]int
+	AreaKm2     float64
+
+	// ChosenMaxLevel is the max level picked by SearchMaxLevel, or -1 if
+	// the max level was not searched for.
+	ChosenMaxLevel int
+
+	// Overshoot is the ratio of covered area to the area of the input
+	// polygons, or zero if unknown. See CoveringOvershoot.
+	Overshoot float64
+
+	// CoverDuration is the wall-clock time taken to compute the covering,
+	// and FeatureCoverDurations the time taken by each feature, or zero
+	// and nil if not measured. Features are covered concurrently, so
+	// their durations may sum to more than the total.
+	CoverDuration         time.Duration
+	FeatureCoverDurations []time.Duration
+}
+
+// ComputeCoveringStats counts cellIDs by level and sums their approximate
+// area on a sphere of earthRadiusKm, such as EarthRadiusKm.
+func ComputeCoveringStats(cellIDs []s2.CellID, earthRadiusKm float64) *CoveringStats {
+	stats := CoveringStats{
+		CellCount:      len(cellIDs),
+		LevelCounts:    make(map[int]int),
+		ChosenMaxLevel: -1,
+	}
+
+	for _, cellID := range cellIDs {
+		stats.LevelCounts[cellID.Level()]++
+		stats.AreaKm2 += CellAreaKm2(cellID, earthRadiusKm)
+	}
+
+	return &stats
+}
+
+// Fprint writes a human-readable summary of s to w.
+func (s *CoveringStats) Fprint(w io.Writer) error {
+	if _, err := fmt.Fprintf(w, "cells: %d\n", s.CellCount); err != nil {
+		return err
+	}
+
+	var levels []int
+	for level := range s.LevelCounts {
+		levels = append(levels, level)
+	}
+	sort.Ints(levels)
+
+	for _, level := range levels {
+		if _, err := fmt.Fprintf(w, "cells at level %d: %d\n", level, s.LevelCounts[level]); err != nil {
+			return err
+		}
+	}
+
+	if _, err := fmt.Fprintf(w, "area: %.3f km²\n", s.AreaKm2); err != nil {
+		return err
+	}
+
+	if s.ChosenMaxLevel >= 0 {
+		if _, err := fmt.Fprintf(w, "chosen max level: %d\n", s.ChosenMaxLevel); err != nil {
+			return err
+		}
+	}
+
+	if s.Overshoot > 0 {
+		if _, err := fmt.Fprintf(w, "overshoot: %.3f\n", s.Overshoot); err != nil {
+			return err
+		}
+	}
+
+	if s.CoverDuration > 0 {
+		if _, err := fmt.Fprintf(w, "covering time: %v\n", s.CoverDuration); err != nil {
+			return err
+		}
+	}
+	for i, d := range s.FeatureCoverDurations {
+		if _, err := fmt.Fprintf(w, "feature %d covering time: %v\n", i, d); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// CoveringOvershoot returns the ratio of the area of cellIDs to the area of
+// poly. Coverings of poly have a ratio of at least 1, while interior
+// coverings fall below 1. Zero is returned if poly has no area.
+func CoveringOvershoot(poly *s2.Polygon, cellIDs []s2.CellID) float64 {
+	polyArea := poly.Area()
+	if polyArea == 0 {
+		return 0
+	}
+
+	var cellArea float64
+	for _, cellID := range cellIDs {
+		cellArea += s2.CellFromCellID(cellID).ApproxArea()
+	}
+
+	return cellArea / polyArea
+}
@@ -0,0 +1,118 @@
+package geokit
+
+import (
+	"bytes"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang/geo/s2"
+)
+
+func TestComputeCoveringStats(t *testing.T) {
+	parent := s2.CellIDFromLatLng(s2.LatLngFromDegrees(47.6, -122.3)).Parent(10)
+	children := parent.Children()
+	cellIDs := []s2.CellID{parent.Next(), children[0], children[1], children[2].ChildBegin()}
+
+	stats := ComputeCoveringStats(cellIDs, EarthRadiusKm)
+	if stats.CellCount != 4 {
+		t.Errorf("got %d cells, want 4", stats.CellCount)
+	}
+	if want := map[int]int{10: 1, 11: 2, 12: 1}; !reflect.DeepEqual(stats.LevelCounts, want) {
+		t.Errorf("got level counts %v, want %v", stats.LevelCounts, want)
+	}
+	if stats.ChosenMaxLevel != -1 {
+		t.Errorf("got chosen max level %d, want -1", stats.ChosenMaxLevel)
+	}
+
+	cu := s2.CellUnion(cellIDs)
+	want := cu.ApproxArea() * EarthRadiusKm * EarthRadiusKm
+	if math.Abs(stats.AreaKm2-want) > 1e-9*want {
+		t.Errorf("got area %v km², want %v", stats.AreaKm2, want)
+	}
+}
+
+func TestComputeCoveringStatsEarthRadius(t *testing.T) {
+	cellIDs := []s2.CellID{s2.CellIDFromFace(0)}
+	unit := ComputeCoveringStats(cellIDs, 1).AreaKm2
+	if want := 4 * math.Pi / 6; math.Abs(unit-want) > 1e-9 {
+		t.Errorf("got face area %v on the unit sphere, want %v", unit, want)
+	}
+	if got := ComputeCoveringStats(cellIDs, 2).AreaKm2; math.Abs(got-4*unit) > 1e-9 {
+		t.Errorf("got %v at twice the radius, want %v", got, 4*unit)
+	}
+}
+
+func TestCoverFeatureEarthRadius(t *testing.T) {
+	// the same polygon on a smaller sphere has less area, so fewer cells
+	// of the target area fit inside it
+	var counts []int
+	for _, radiusKm := range []float64{0, EarthRadiusKm / 10} {
+		feat := polygonFeature(squareRing(0, 0, 1))
+		c := Coverer{MinLevel: 4, MaxLevel: 16, MaxCells: 1000, TargetCellAreaKm2: 100, EarthRadiusKm: radiusKm}
+		cellIDs, err := c.CoverFeature(&feat)
+		if err != nil {
+			t.Fatal(err)
+		}
+		counts = append(counts, len(cellIDs))
+	}
+	if counts[1] >= counts[0] {
+		t.Errorf("got %d cells on a tenth the radius, want fewer than %d", counts[1], counts[0])
+	}
+}
+
+func TestCoveringOvershoot(t *testing.T) {
+	poly, err := GeoJSONPolygonToS2Polygon(&GeoJSONPolygonGeometry{Coordinates: [][][2]float64{squareRing(0, 0, 1)}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range []struct {
+		name     string
+		interior bool
+		check    func(float64) bool
+	}{
+		{"covering", false, func(o float64) bool { return o >= 1 }},
+		{"interior covering", true, func(o float64) bool { return o > 0 && o < 1 }},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			cellIDs := Cover(poly, 4, 12, 100, tt.interior)
+			if o := CoveringOvershoot(poly, cellIDs); !tt.check(o) {
+				t.Errorf("got overshoot %v", o)
+			}
+		})
+	}
+
+	if o := CoveringOvershoot(s2.PolygonFromLoops(nil), []s2.CellID{s2.CellIDFromFace(0)}); o != 0 {
+		t.Errorf("got overshoot %v for an empty polygon, want 0", o)
+	}
+}
+
+func TestCoveringStatsFprint(t *testing.T) {
+	stats := CoveringStats{
+		CellCount:      3,
+		LevelCounts:    map[int]int{12: 1, 4: 2},
+		AreaKm2:        1.5,
+		ChosenMaxLevel: -1,
+		CoverDuration:  time.Second,
+	}
+
+	var buf bytes.Buffer
+	if err := stats.Fprint(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	want := strings.Join([]string{
+		"cells: 3",
+		"cells at level 4: 2",
+		"cells at level 12: 1",
+		"area: 1.500 km²",
+		"covering time: 1s",
+		"",
+	}, "\n")
+	if buf.String() != want {
+		t.Errorf("got\n%s\nwant\n%s", buf.String(), want)
+	}
+}
@@ -0,0 +1,178 @@
+package geokit
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+)
+
+// topology is a TopoJSON document, of which only the parts needed to
+// rebuild Polygon and MultiPolygon geometries are decoded
+type topology struct {
+	Type      string                      `json:"type"`
+	Transform *topologyTransform          `json:"transform"`
+	Arcs      [][][]float64               `json:"arcs"`
+	Objects   map[string]topologyGeometry `json:"objects"`
+}
+
+type topologyTransform struct {
+	Scale     [2]float64 `json:"scale"`
+	Translate [2]float64 `json:"translate"`
+}
+
+type topologyGeometry struct {
+	Type       string                 `json:"type"`
+	ID         interface{}            `json:"id"`
+	Properties map[string]interface{} `json:"properties"`
+	Geometries []topologyGeometry     `json:"geometries"`
+
+	// rings of arc indexes for a Polygon, polygons of them for a
+	// MultiPolygon
+	Arcs json.RawMessage `json:"arcs"`
+}
+
+// DecodeTopoJSONFeatures reads a TopoJSON Topology from r and returns a
+// feature for each Polygon and MultiPolygon geometry in its objects, in
+// order of object name. GeometryCollections are flattened and null
+// geometries become features with a null geometry.
+func DecodeTopoJSONFeatures(r io.Reader) ([]GeoJSONFeature, error) {
+	r, err := maybeGunzip(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var topo topology
+	if err := json.NewDecoder(r).Decode(&topo); err != nil {
+		return nil, fmt.Errorf("json decode failed: %v", err)
+	}
+
+	if topo.Type != "Topology" {
+		return nil, fmt.Errorf("TopoJSON document type unsupported: %v", topo.Type)
+	}
+
+	arcs, err := topo.decodeArcs()
+	if err != nil {
+		return nil, err
+	}
+
+	// objects is a JSON object, so sort for a stable feature order
+	names := make([]string, 0, len(topo.Objects))
+	for name := range topo.Objects {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var feats []GeoJSONFeature
+	for _, name := range names {
+		feats, err = appendTopologyFeatures(feats, topo.Objects[name], arcs)
+		if err != nil {
+			return nil, fmt.Errorf("object %q: %v", name, err)
+		}
+	}
+
+	return feats, nil
+}
+
+// decodeArcs returns the arcs of the topology as absolute [lng, lat]
+// positions, undoing the delta encoding of quantized topologies
+func (t *topology) decodeArcs() ([][][2]float64, error) {
+	arcs := make([][][2]float64, len(t.Arcs))
+	for i, arc := range t.Arcs {
+		var x, y float64
+		for j, pos := range arc {
+			if len(pos) < 2 {
+				return nil, fmt.Errorf("arc %d: position %d has %d values, need at least 2", i, j, len(pos))
+			}
+
+			p := [2]float64{pos[0], pos[1]}
+			if t.Transform != nil {
+				x, y = x+pos[0], y+pos[1]
+				p = [2]float64{
+					x*t.Transform.Scale[0] + t.Transform.Translate[0],
+					y*t.Transform.Scale[1] + t.Transform.Translate[1],
+				}
+			}
+			arcs[i] = append(arcs[i], p)
+		}
+	}
+	return arcs, nil
+}
+
+func appendTopologyFeatures(feats []GeoJSONFeature, g topologyGeometry, arcs [][][2]float64) ([]GeoJSONFeature, error) {
+	feat := GeoJSONFeature{Type: "Feature", ID: g.ID, Properties: g.Properties}
+
+	switch g.Type {
+	case "GeometryCollection":
+		var err error
+		for i, member := range g.Geometries {
+			if feats, err = appendTopologyFeatures(feats, member, arcs); err != nil {
+				return nil, fmt.Errorf("geometry %d: %v", i, err)
+			}
+		}
+		return feats, nil
+	case "":
+		// a null geometry, left for the caller to skip like GeoJSON's
+	case "Polygon":
+		var rings [][]int
+		if err := json.Unmarshal(g.Arcs, &rings); err != nil {
+			return nil, fmt.Errorf("invalid Polygon arcs: %v", err)
+		}
+		poly, err := topologyPolygon(rings, arcs)
+		if err != nil {
+			return nil, err
+		}
+		feat.Geometry = GeoJSONGeometry{Type: "Polygon", Coordinates: poly}
+	case "MultiPolygon":
+		var polys [][][]int
+		if err := json.Unmarshal(g.Arcs, &polys); err != nil {
+			return nil, fmt.Errorf("invalid MultiPolygon arcs: %v", err)
+		}
+		var coords [][][][2]float64
+		for i, rings := range polys {
+			poly, err := topologyPolygon(rings, arcs)
+			if err != nil {
+				return nil, fmt.Errorf("polygon %d: %v", i, err)
+			}
+			coords = append(coords, poly)
+		}
+		feat.Geometry = GeoJSONGeometry{Type: "MultiPolygon", Coordinates: coords}
+	default:
+		return nil, fmt.Errorf("unsupported TopoJSON geometry type %q", g.Type)
+	}
+
+	return append(feats, feat), nil
+}
+
+// topologyPolygon stitches each ring of arc indexes into a ring of
+// positions
+func topologyPolygon(rings [][]int, arcs [][][2]float64) ([][][2]float64, error) {
+	poly := make([][][2]float64, len(rings))
+	for i, ring := range rings {
+		for _, idx := range ring {
+			// a negative index ~i refers to arc i reversed
+			reversed := idx < 0
+			if reversed {
+				idx = ^idx
+			}
+			if idx >= len(arcs) {
+				return nil, fmt.Errorf("ring %d: arc %d out of range", i, idx)
+			}
+
+			arc := arcs[idx]
+			if reversed {
+				arc = make([][2]float64, len(arcs[idx]))
+				for j, pos := range arcs[idx] {
+					arc[len(arc)-1-j] = pos
+				}
+			}
+
+			// consecutive arcs share their joining position
+			if len(poly[i]) > 0 && len(arc) > 0 {
+				arc = arc[1:]
+			}
+			poly[i] = append(poly[i], arc...)
+		}
+	}
+	return poly, nil
+}
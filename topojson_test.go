@@ -0,0 +1,93 @@
+package geokit
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDecodeTopoJSONFeatures(t *testing.T) {
+	// two unit squares side by side, sharing the arc between them
+	west := polygonFeature([][2]float64{{0, 0}, {1, 0}, {1, 1}, {0, 1}, {0, 0}})
+	east := polygonFeature([][2]float64{{1, 0}, {2, 0}, {2, 1}, {1, 1}, {1, 0}})
+	const arcs = `[[[1,0],[1,1]],[[1,1],[0,1],[0,0],[1,0]],[[1,0],[2,0],[2,1],[1,1]]]`
+
+	for _, tt := range []struct {
+		name string
+		doc  string
+		want []GeoJSONFeature
+	}{
+		{
+			name: "shared arc",
+			doc:  `{"type":"Topology","arcs":` + arcs + `,"objects":{"squares":{"type":"GeometryCollection","geometries":[{"type":"Polygon","arcs":[[0,1]]},{"type":"Polygon","arcs":[[2,-1]]}]}}}`,
+			want: []GeoJSONFeature{west, east},
+		},
+		{
+			// the same squares, quantized to a grid with half-degree steps
+			// and delta encoded
+			name: "quantized",
+			doc:  `{"type":"Topology","transform":{"scale":[0.5,0.5],"translate":[0,0]},"arcs":[[[2,0],[0,2]],[[2,2],[-2,0],[0,-2],[2,0]],[[2,0],[2,0],[0,2],[-2,0]]],"objects":{"squares":{"type":"GeometryCollection","geometries":[{"type":"Polygon","arcs":[[0,1]]},{"type":"Polygon","arcs":[[2,-1]]}]}}}`,
+			want: []GeoJSONFeature{west, east},
+		},
+		{
+			name: "MultiPolygon",
+			doc:  `{"type":"Topology","arcs":` + arcs + `,"objects":{"squares":{"type":"MultiPolygon","arcs":[[[0,1]],[[2,-1]]]}}}`,
+			want: []GeoJSONFeature{{Type: "Feature", Geometry: GeoJSONGeometry{
+				Type:        "MultiPolygon",
+				Coordinates: [][][][2]float64{west.Geometry.Coordinates.([][][2]float64), east.Geometry.Coordinates.([][][2]float64)},
+			}}},
+		},
+		{
+			// objects come out by name, not document order
+			name: "object order",
+			doc:  `{"type":"Topology","arcs":` + arcs + `,"objects":{"b":{"type":"Polygon","arcs":[[2,-1]]},"a":{"type":"Polygon","arcs":[[0,1]]}}}`,
+			want: []GeoJSONFeature{west, east},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			feats, err := DecodeTopoJSONFeatures(strings.NewReader(tt.doc))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(feats) != len(tt.want) {
+				t.Fatalf("got %d features, want %d", len(feats), len(tt.want))
+			}
+
+			// covers the same as the GeoJSON the topology was built from
+			c := Coverer{MinLevel: 4, MaxLevel: 12, MaxCells: 100}
+			for i := range feats {
+				got, err := c.CoverFeature(&feats[i])
+				if err != nil {
+					t.Fatal(err)
+				}
+				want, err := c.CoverFeature(&tt.want[i])
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !reflect.DeepEqual(got, want) {
+					t.Errorf("feature %d: got covering %v, want %v", i, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestDecodeTopoJSONFeaturesErrors(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		doc  string
+		want string
+	}{
+		{"not a topology", `{"type":"FeatureCollection"}`, "TopoJSON document type unsupported"},
+		{"arc out of range", `{"type":"Topology","arcs":[],"objects":{"a":{"type":"Polygon","arcs":[[0]]}}}`, `object "a": ring 0: arc 0 out of range`},
+		{"short position", `{"type":"Topology","arcs":[[[0]]],"objects":{}}`, "arc 0: position 0 has 1 values"},
+		{"point", `{"type":"Topology","arcs":[],"objects":{"a":{"type":"Point","coordinates":[0,0]}}}`, `unsupported TopoJSON geometry type "Point"`},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := DecodeTopoJSONFeatures(strings.NewReader(tt.doc))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("got error %v, want one containing %q", err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,158 @@
+package geokit
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+)
+
+// geometryDepths is how deeply positions are nested in the coordinates of
+// each geometry type, which must be those TypedGeometry decodes
+var geometryDepths = map[string]int{
+	"Point":           0,
+	"LineString":      1,
+	"MultiLineString": 2,
+	"Polygon":         2,
+	"MultiPolygon":    3,
+}
+
+// DecodeValidatedGeoJSONFeatures is DecodeGeoJSONFeatures, but first checks
+// the structure of the document against RFC 7946, so that malformed input
+// is reported by the path of the offending member, such as
+// features[3].geometry.coordinates[0], rather than by a JSON decoding error.
+func DecodeValidatedGeoJSONFeatures(r io.Reader) ([]GeoJSONFeature, error) {
+	r, err := maybeGunzip(r)
+	if err != nil {
+		return nil, err
+	}
+
+	raw, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var doc interface{}
+	if err := json.Unmarshal(raw, &doc); err != nil {
+		return nil, fmt.Errorf("json decode failed: %v", err)
+	}
+	if err := validateFeatureCollection(doc); err != nil {
+		return nil, err
+	}
+
+	return DecodeGeoJSONFeatures(bytes.NewReader(raw))
+}
+
+func validateFeatureCollection(doc interface{}) error {
+	fc, ok := doc.(map[string]interface{})
+	if !ok {
+		return fmt.Errorf("document must be an object")
+	}
+	if fc["type"] != "FeatureCollection" {
+		return fmt.Errorf("type: must be FeatureCollection, got %v", fc["type"])
+	}
+
+	feats, ok := fc["features"].([]interface{})
+	if !ok {
+		return fmt.Errorf("features: must be an array")
+	}
+
+	for i, feat := range feats {
+		if err := validateFeature(feat, fmt.Sprintf("features[%d]", i)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func validateFeature(v interface{}, path string) error {
+	feat, ok := v.(map[string]interface{})
+	if !ok {
+		return fmt.Errorf("%s: must be an object", path)
+	}
+	if feat["type"] != "Feature" {
+		return fmt.Errorf("%s.type: must be Feature, got %v", path, feat["type"])
+	}
+	if props, ok := feat["properties"]; ok && props != nil {
+		if _, ok := props.(map[string]interface{}); !ok {
+			return fmt.Errorf("%s.properties: must be an object or null", path)
+		}
+	}
+
+	geo, ok := feat["geometry"]
+	if !ok {
+		return fmt.Errorf("%s.geometry: missing", path)
+	}
+	if geo == nil {
+		return nil
+	}
+	return validateGeometry(geo, path+".geometry")
+}
+
+func validateGeometry(v interface{}, path string) error {
+	geo, ok := v.(map[string]interface{})
+	if !ok {
+		return fmt.Errorf("%s: must be an object or null", path)
+	}
+
+	typ, _ := geo["type"].(string)
+	depth, ok := geometryDepths[typ]
+	if !ok {
+		return fmt.Errorf("%s.type: unsupported geometry type %v", path, geo["type"])
+	}
+
+	coords, ok := geo["coordinates"]
+	if !ok {
+		return fmt.Errorf("%s.coordinates: missing", path)
+	}
+	return validateCoordinates(typ, coords, depth, path+".coordinates")
+}
+
+// validateCoordinates checks that v nests positions depth arrays deep, and
+// that lines and rings have enough of them
+func validateCoordinates(typ string, v interface{}, depth int, path string) error {
+	arr, ok := v.([]interface{})
+	if !ok {
+		return fmt.Errorf("%s: must be an array", path)
+	}
+
+	if depth == 0 {
+		if len(arr) < 2 {
+			return fmt.Errorf("%s: position must have at least 2 values, got %d", path, len(arr))
+		}
+		for i, val := range arr {
+			if _, ok := val.(float64); !ok {
+				return fmt.Errorf("%s[%d]: must be a number", path, i)
+			}
+		}
+		return nil
+	}
+
+	// the innermost arrays of positions are lines, or rings of polygons
+	if depth == 1 {
+		switch typ {
+		case "LineString", "MultiLineString":
+			if len(arr) < 2 {
+				return fmt.Errorf("%s: line must have at least 2 positions, got %d", path, len(arr))
+			}
+		case "Polygon", "MultiPolygon":
+			// rings may leave out the closing position, as
+			// GeoJSONPolygonToS2Polygon closes them itself
+			if len(arr) > 1 && reflect.DeepEqual(arr[0], arr[len(arr)-1]) {
+				if len(arr) < 4 {
+					return fmt.Errorf("%s: closed ring must have at least 4 positions, got %d", path, len(arr))
+				}
+			} else if len(arr) < 3 {
+				return fmt.Errorf("%s: ring must have at least 3 positions, got %d", path, len(arr))
+			}
+		}
+	}
+
+	for i, elem := range arr {
+		if err := validateCoordinates(typ, elem, depth-1, fmt.Sprintf("%s[%d]", path, i)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,54 @@
+package geokit
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDecodeValidatedGeoJSONFeatures(t *testing.T) {
+	feature := func(geometry string) string {
+		return `{"type":"FeatureCollection","features":[{"type":"Feature","properties":{},"geometry":` + geometry + `}]}`
+	}
+
+	for _, tt := range []struct {
+		name    string
+		doc     string
+		wantErr string
+	}{
+		{name: "polygon", doc: feature(`{"type":"Polygon","coordinates":[[[0,0],[1,0],[1,1],[0,0]]]}`)},
+		{name: "null geometry", doc: feature(`null`)},
+		{name: "point with altitude", doc: feature(`{"type":"Point","coordinates":[1,2,3]}`)},
+		{name: "not a collection", doc: `{"type":"Feature"}`, wantErr: "type: must be FeatureCollection"},
+		{name: "missing features", doc: `{"type":"FeatureCollection"}`, wantErr: "features: must be an array"},
+		{name: "not a feature", doc: `{"type":"FeatureCollection","features":[1]}`, wantErr: "features[0]: must be an object"},
+		{name: "missing geometry", doc: `{"type":"FeatureCollection","features":[{"type":"Feature"}]}`, wantErr: "features[0].geometry: missing"},
+		{name: "bad properties", doc: `{"type":"FeatureCollection","features":[{"type":"Feature","properties":[],"geometry":null}]}`, wantErr: "features[0].properties: must be an object or null"},
+		{name: "missing coordinates", doc: feature(`{"type":"Polygon"}`), wantErr: "features[0].geometry.coordinates: missing"},
+		// TypedGeometry can't decode these, so neither may validation pass them
+		{name: "MultiPoint", doc: feature(`{"type":"MultiPoint","coordinates":[[0,0]]}`), wantErr: "features[0].geometry.type: unsupported geometry type MultiPoint"},
+		{name: "GeometryCollection", doc: feature(`{"type":"GeometryCollection","geometries":[]}`), wantErr: "unsupported geometry type GeometryCollection"},
+		{name: "open ring", doc: feature(`{"type":"Polygon","coordinates":[[[0,0],[1,0],[1,1]]]}`)},
+		{name: "short ring", doc: feature(`{"type":"Polygon","coordinates":[[[0,0],[1,0],[0,0]]]}`), wantErr: "features[0].geometry.coordinates[0]: closed ring must have at least 4 positions, got 3"},
+		{name: "short open ring", doc: feature(`{"type":"MultiPolygon","coordinates":[[[[0,0],[1,0]]]]}`), wantErr: "features[0].geometry.coordinates[0][0]: ring must have at least 3 positions, got 2"},
+		{name: "short line", doc: feature(`{"type":"MultiLineString","coordinates":[[[0,0],[1,1]],[[0,0]]]}`), wantErr: "features[0].geometry.coordinates[1]: line must have at least 2 positions, got 1"},
+		{name: "too shallow", doc: feature(`{"type":"Polygon","coordinates":[[0,0,1,1]]}`), wantErr: "features[0].geometry.coordinates[0][0]: must be an array"},
+		{name: "string value", doc: feature(`{"type":"Point","coordinates":[0,"1"]}`), wantErr: "features[0].geometry.coordinates[1]: must be a number"},
+		{name: "short position", doc: feature(`{"type":"LineString","coordinates":[[0,0],[1]]}`), wantErr: "features[0].geometry.coordinates[1]: position must have at least 2 values, got 1"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			feats, err := DecodeValidatedGeoJSONFeatures(strings.NewReader(tt.doc))
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("got error %v, want one containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(feats) != 1 {
+				t.Errorf("got %d features, want 1", len(feats))
+			}
+		})
+	}
+}
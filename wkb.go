@@ -0,0 +1,212 @@
+package geokit
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+)
+
+const (
+	wkbPolygon      = 3
+	wkbMultiPolygon = 6
+
+	// PostGIS extended WKB flags
+	ewkbZ    = 0x80000000
+	ewkbM    = 0x40000000
+	ewkbSRID = 0x20000000
+)
+
+// DecodeWKBHexFeatures reads one hex-encoded WKB geometry per line from r,
+// as PostGIS prints them, and returns a feature for each. Only Polygon and
+// MultiPolygon geometries are supported. Z and M values are dropped.
+func DecodeWKBHexFeatures(r io.Reader) ([]GeoJSONFeature, error) {
+	var feats []GeoJSONFeature
+
+	scanner := bufio.NewScanner(r)
+	// a single geometry can easily outgrow the default line limit
+	scanner.Buffer(nil, 64*1024*1024)
+
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+
+		raw, err := hex.DecodeString(text)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid hex: %v", line, err)
+		}
+
+		geo, err := decodeWKB(bytes.NewReader(raw))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", line, err)
+		}
+
+		feats = append(feats, GeoJSONFeature{Type: "Feature", Geometry: *geo})
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return feats, nil
+}
+
+func decodeWKB(r *bytes.Reader) (*GeoJSONGeometry, error) {
+	wr, geomType, err := readWKBHeader(r)
+	if err != nil {
+		return nil, err
+	}
+
+	switch geomType {
+	case wkbPolygon:
+		rings, err := wr.readPolygon()
+		if err != nil {
+			return nil, err
+		}
+		return &GeoJSONGeometry{Type: "Polygon", Coordinates: rings}, nil
+	case wkbMultiPolygon:
+		n, err := wr.readUint32()
+		if err != nil {
+			return nil, err
+		}
+
+		var polys [][][][2]float64
+		for i := 0; i < int(n); i++ {
+			// each member carries its own header, byte order included
+			pr, memberType, err := readWKBHeader(r)
+			if err != nil {
+				return nil, fmt.Errorf("polygon %d: %v", i, err)
+			}
+			if memberType != wkbPolygon {
+				return nil, fmt.Errorf("polygon %d: unexpected WKB geometry type %d", i, memberType)
+			}
+			rings, err := pr.readPolygon()
+			if err != nil {
+				return nil, fmt.Errorf("polygon %d: %v", i, err)
+			}
+			polys = append(polys, rings)
+		}
+		return &GeoJSONGeometry{Type: "MultiPolygon", Coordinates: polys}, nil
+	default:
+		return nil, fmt.Errorf("unsupported WKB geometry type %d", geomType)
+	}
+}
+
+// wkbReader decodes the body of a single WKB geometry
+type wkbReader struct {
+	r     *bytes.Reader
+	order binary.ByteOrder
+
+	// number of values per position
+	dims int
+}
+
+// readWKBHeader reads the byte order and geometry type that open every WKB
+// geometry, returning the base 2D type with any Z, M or SRID markers
+// stripped
+func readWKBHeader(r *bytes.Reader) (*wkbReader, uint32, error) {
+	b, err := r.ReadByte()
+	if err != nil {
+		return nil, 0, errWKBTruncated
+	}
+
+	wr := wkbReader{r: r, dims: 2}
+	switch b {
+	case 0:
+		wr.order = binary.BigEndian
+	case 1:
+		wr.order = binary.LittleEndian
+	default:
+		return nil, 0, fmt.Errorf("invalid WKB byte order %d", b)
+	}
+
+	geomType, err := wr.readUint32()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if geomType&ewkbSRID != 0 {
+		if _, err := wr.readUint32(); err != nil {
+			return nil, 0, err
+		}
+	}
+	if geomType&ewkbZ != 0 {
+		wr.dims++
+	}
+	if geomType&ewkbM != 0 {
+		wr.dims++
+	}
+	geomType &^= ewkbZ | ewkbM | ewkbSRID
+
+	// ISO WKB marks Z and M by adding 1000, 2000 or 3000 to the type
+	switch geomType / 1000 {
+	case 1, 2:
+		wr.dims++
+	case 3:
+		wr.dims += 2
+	}
+	geomType %= 1000
+
+	return &wr, geomType, nil
+}
+
+var errWKBTruncated = errors.New("truncated WKB")
+
+func (wr *wkbReader) readUint32() (uint32, error) {
+	var buf [4]byte
+	if _, err := io.ReadFull(wr.r, buf[:]); err != nil {
+		return 0, errWKBTruncated
+	}
+	return wr.order.Uint32(buf[:]), nil
+}
+
+func (wr *wkbReader) readFloat64() (float64, error) {
+	var buf [8]byte
+	if _, err := io.ReadFull(wr.r, buf[:]); err != nil {
+		return 0, errWKBTruncated
+	}
+	return math.Float64frombits(wr.order.Uint64(buf[:])), nil
+}
+
+// readPolygon reads the rings of a Polygon as GeoJSON [lng, lat] positions
+func (wr *wkbReader) readPolygon() ([][][2]float64, error) {
+	numRings, err := wr.readUint32()
+	if err != nil {
+		return nil, err
+	}
+
+	// counts come from untrusted input, so grow slices as data arrives
+	// rather than allocating up front
+	var rings [][][2]float64
+	for i := uint32(0); i < numRings; i++ {
+		numPoints, err := wr.readUint32()
+		if err != nil {
+			return nil, err
+		}
+
+		var ring [][2]float64
+		for j := uint32(0); j < numPoints; j++ {
+			var pos [2]float64
+			for d := 0; d < wr.dims; d++ {
+				v, err := wr.readFloat64()
+				if err != nil {
+					return nil, err
+				}
+				if d < 2 {
+					pos[d] = v
+				}
+			}
+			ring = append(ring, pos)
+		}
+		rings = append(rings, ring)
+	}
+
+	return rings, nil
+}
@@ -0,0 +1,129 @@
+package geokit
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// encodeWKB returns the WKB form of a Polygon, or a MultiPolygon if there
+// are several, with extra zero values after each position when dims > 2
+func encodeWKB(order binary.ByteOrder, geomType uint32, srid bool, dims int, polys ...[][][2]float64) []byte {
+	var buf bytes.Buffer
+	header := func(t uint32) {
+		if order == binary.LittleEndian {
+			buf.WriteByte(1)
+		} else {
+			buf.WriteByte(0)
+		}
+		binary.Write(&buf, order, t)
+		if srid {
+			binary.Write(&buf, order, uint32(4326))
+		}
+	}
+	polygon := func(rings [][][2]float64) {
+		binary.Write(&buf, order, uint32(len(rings)))
+		for _, ring := range rings {
+			binary.Write(&buf, order, uint32(len(ring)))
+			for _, pos := range ring {
+				binary.Write(&buf, order, pos)
+				for d := 2; d < dims; d++ {
+					binary.Write(&buf, order, float64(0))
+				}
+			}
+		}
+	}
+
+	if len(polys) == 1 {
+		header(geomType | wkbPolygon)
+		polygon(polys[0])
+		return buf.Bytes()
+	}
+
+	header(geomType | wkbMultiPolygon)
+	binary.Write(&buf, order, uint32(len(polys)))
+	for _, poly := range polys {
+		header(geomType | wkbPolygon)
+		polygon(poly)
+	}
+	return buf.Bytes()
+}
+
+func TestDecodeWKBHexFeatures(t *testing.T) {
+	square := [][][2]float64{squareRing(0, 0, 1)}
+	holed := [][][2]float64{squareRing(5, 5, 4), reversed(squareRing(6, 6, 1))}
+
+	for _, tt := range []struct {
+		name  string
+		wkb   []byte
+		typ   string
+		polys [][][][2]float64
+	}{
+		{"little endian", encodeWKB(binary.LittleEndian, 0, false, 2, square), "Polygon", [][][][2]float64{square}},
+		{"big endian", encodeWKB(binary.BigEndian, 0, false, 2, square), "Polygon", [][][][2]float64{square}},
+		{"with a hole", encodeWKB(binary.LittleEndian, 0, false, 2, holed), "Polygon", [][][][2]float64{holed}},
+		{"EWKB with Z and SRID", encodeWKB(binary.LittleEndian, ewkbZ|ewkbSRID, true, 3, square), "Polygon", [][][][2]float64{square}},
+		{"EWKB with Z and M", encodeWKB(binary.BigEndian, ewkbZ|ewkbM, false, 4, square), "Polygon", [][][][2]float64{square}},
+		{"ISO Z", encodeWKB(binary.LittleEndian, 1000, false, 3, square), "Polygon", [][][][2]float64{square}},
+		{"ISO ZM", encodeWKB(binary.LittleEndian, 3000, false, 4, square), "Polygon", [][][][2]float64{square}},
+		{"MultiPolygon", encodeWKB(binary.LittleEndian, 0, false, 2, square, holed), "MultiPolygon", [][][][2]float64{square, holed}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			feats, err := DecodeWKBHexFeatures(strings.NewReader(hex.EncodeToString(tt.wkb) + "\n\n"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(feats) != 1 {
+				t.Fatalf("got %d features, want 1", len(feats))
+			}
+			if feats[0].Geometry.Type != tt.typ {
+				t.Fatalf("got a %s, want a %s", feats[0].Geometry.Type, tt.typ)
+			}
+
+			// covers the same as the GeoJSON it was encoded from
+			want := GeoJSONFeature{Type: "Feature", Geometry: GeoJSONGeometry{Type: "MultiPolygon", Coordinates: tt.polys}}
+			if tt.typ == "Polygon" {
+				want = polygonFeature(tt.polys[0]...)
+			}
+			c := Coverer{MinLevel: 4, MaxLevel: 12, MaxCells: 100}
+			got, err := c.CoverFeature(&feats[0])
+			if err != nil {
+				t.Fatal(err)
+			}
+			wantCells, err := c.CoverFeature(&want)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, wantCells) {
+				t.Errorf("got covering %v, want %v", got, wantCells)
+			}
+		})
+	}
+}
+
+func TestDecodeWKBHexFeaturesErrors(t *testing.T) {
+	square := encodeWKB(binary.LittleEndian, 0, false, 2, [][][2]float64{squareRing(0, 0, 1)})
+	point := []byte{1, 1, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0}
+
+	for _, tt := range []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"not hex", "zz", "line 1: invalid hex"},
+		{"truncated", hex.EncodeToString(square[:len(square)-4]), "line 1: truncated WKB"},
+		{"byte order", "02" + hex.EncodeToString(square[1:]), "invalid WKB byte order 2"},
+		{"point", hex.EncodeToString(point), "unsupported WKB geometry type 1"},
+		{"second line", hex.EncodeToString(square) + "\n" + hex.EncodeToString(point), "line 2:"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := DecodeWKBHexFeatures(strings.NewReader(tt.in))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("got error %v, want one containing %q", err, tt.want)
+			}
+		})
+	}
+}
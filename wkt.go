@@ -0,0 +1,26 @@
+package geokit
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/golang/geo/s2"
+)
+
+// CellToWKT returns the WKT POLYGON describing the boundary of cellID.
+func CellToWKT(cellID s2.CellID) string {
+	var b strings.Builder
+	b.WriteString("POLYGON((")
+	for i, point := range EdgesOfCell(s2.CellFromCellID(cellID)) {
+		if i > 0 {
+			b.WriteString(", ")
+		}
+
+		// WKT orders coordinates as lng lat
+		b.WriteString(strconv.FormatFloat(point[1], 'f', -1, 64))
+		b.WriteString(" ")
+		b.WriteString(strconv.FormatFloat(point[0], 'f', -1, 64))
+	}
+	b.WriteString("))")
+	return b.String()
+}
@@ -0,0 +1,32 @@
+package geokit
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/golang/geo/s2"
+)
+
+func TestCellToWKT(t *testing.T) {
+	cellID := s2.CellIDFromLatLng(s2.LatLngFromDegrees(47.6, -122.3)).Parent(12)
+	wkt := CellToWKT(cellID)
+
+	if !strings.HasPrefix(wkt, "POLYGON((") || !strings.HasSuffix(wkt, "))") {
+		t.Fatalf("got %q, want a POLYGON", wkt)
+	}
+
+	// the same vertices as the GeoJSON outline, lng first
+	points := strings.Split(strings.TrimSuffix(strings.TrimPrefix(wkt, "POLYGON(("), "))"), ", ")
+	ring := cellRing(s2.CellFromCellID(cellID))
+	if len(points) != len(ring) {
+		t.Fatalf("got %d points, want %d", len(points), len(ring))
+	}
+	for i, p := range points {
+		if !strings.HasPrefix(p, "-122.") || !strings.Contains(p, " 47.") {
+			t.Errorf("point %d: got %q, want lng then lat", i, p)
+		}
+	}
+	if points[0] != points[len(points)-1] {
+		t.Errorf("ring isn't closed: %q", wkt)
+	}
+}